@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// Telnet commands used to put clients in character mode.
+const (
+	telnetIAC  = 255 // Interpret as command
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250 // Subnegotiation begin
+	telnetSE   = 240 // Subnegotiation end
+
+	telnetEcho            = 1
+	telnetSuppressGoAhead = 3
+	telnetLinemode        = 34
+)
+
+// Keys that quit a session.
+const (
+	ctrlC = 3
+	ctrlD = 4
+)
+
+// arrowKeys are the buttons by the last byte of their escape sequence, which
+// is "ESC [ A" or "ESC O A" for the up arrow.
+var arrowKeys = map[byte]Button{
+	'A': ButtonUp,
+	'B': ButtonDown,
+	'C': ButtonRight,
+	'D': ButtonLeft,
+}
+
+// ANSI draws the screen with ANSI escape sequences and reads keys from the same
+// stream, so that a session can run over a network connection. Telnet commands
+// in the input are skipped.
+type ANSI struct {
+	Mode    RenderMode
+	Palette Palette
+	Colors  string // 16, 256 or truecolor
+
+	stream io.ReadWriter
+	reader *bufio.Reader
+	frame  [width * height]byte // Intensities drawn by the last render
+	drawn  bool
+	err    error // Error of the last write, nothing is drawn after one
+}
+
+func NewANSI(stream io.ReadWriter, config Config) (*ANSI, error) {
+	if _, ok := sgrColors[config.Colors]; !ok {
+		return nil, fmt.Errorf("Unknown color mode: %s", config.Colors)
+	}
+
+	mode := config.Mode
+	if mode == ModeAuto {
+		// Fits the 80x24 terminals most clients start with
+		mode = ModeHalf
+	}
+
+	return &ANSI{
+		Mode:    mode,
+		Palette: config.Palette,
+		Colors:  config.Colors,
+		stream:  stream,
+		reader:  bufio.NewReader(stream),
+	}, nil
+}
+
+// Init asks telnet clients to send every key as it is typed without echoing
+// it, then clears the screen and hides the cursor.
+func (ansi *ANSI) Init() error {
+	_, err := ansi.stream.Write([]byte{
+		telnetIAC, telnetWILL, telnetEcho,
+		telnetIAC, telnetWILL, telnetSuppressGoAhead,
+		telnetIAC, telnetDONT, telnetLinemode,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(ansi.stream, "\x1b[2J\x1b[?25l")
+
+	return err
+}
+
+// Close resets the colors, shows the cursor and clears the screen again.
+func (ansi *ANSI) Close() {
+	io.WriteString(ansi.stream, "\x1b[0m\x1b[2J\x1b[H\x1b[?25h")
+}
+
+func (ansi *ANSI) Run(loop func() error) error {
+	return loop()
+}
+
+func (ansi *ANSI) ReportsReleases() bool {
+	return false
+}
+
+// Render writes the rows of cells that changed since the last render in a
+// single write.
+func (ansi *ANSI) Render(frame *[width * height]byte) {
+	if ansi.err != nil {
+		return
+	}
+
+	buffer := bytes.Buffer{}
+	columns, rows := ansi.Mode.Size()
+	_, cellHeight := ansi.Mode.CellSize()
+	gradient := ansi.Colors != "16"
+
+	for row := 0; row < rows; row++ {
+		if ansi.drawn && !rowsChanged(&ansi.frame, frame, row*cellHeight, cellHeight) {
+			continue
+		}
+
+		fmt.Fprintf(&buffer, "\x1b[%d;1H", row+1)
+
+		var last Cell
+		for column := 0; column < columns; column++ {
+			cell := ansi.Mode.Cell(frame, ansi.Palette, column, row, gradient)
+
+			if column == 0 || cell.Fg != last.Fg || cell.Bg != last.Bg {
+				buffer.WriteString(ansi.sgr(cell.Fg, cell.Bg))
+			}
+
+			buffer.WriteRune(cell.Ch)
+			last = cell
+		}
+	}
+
+	ansi.frame = *frame
+	ansi.drawn = true
+
+	if buffer.Len() > 0 {
+		buffer.WriteString("\x1b[0m")
+		_, ansi.err = ansi.stream.Write(buffer.Bytes())
+	}
+}
+
+var sgrColors = map[string]func(color Color, base int) string{
+	"16": func(color Color, base int) string {
+		return fmt.Sprintf("%d", base+color.Index16())
+	},
+	"256": func(color Color, base int) string {
+		return fmt.Sprintf("%d;5;%d", base+8, color.Index256())
+	},
+	"truecolor": func(color Color, base int) string {
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, color.R, color.G, color.B)
+	},
+}
+
+// sgr returns the escape sequence setting the foreground and background colors.
+func (ansi *ANSI) sgr(fg, bg Color) string {
+	format := sgrColors[ansi.Colors]
+
+	return "\x1b[" + format(fg, 30) + ";" + format(bg, 40) + "m"
+}
+
+// PollEvent returns the next key press. Ctrl-C, Ctrl-D or a closed stream
+// quit the session, other keys outside of the keypad are ignored.
+func (ansi *ANSI) PollEvent() Event {
+	for {
+		b, err := ansi.reader.ReadByte()
+
+		if err != nil {
+			return Event{Quit: true}
+		}
+
+		switch b {
+		case telnetIAC:
+			if err := ansi.skipTelnetCommand(); err != nil {
+				return Event{Quit: true}
+			}
+		case ctrlC, ctrlD:
+			return Event{Quit: true}
+		case '\x1b':
+			if button, ok := ansi.readArrowKey(); ok {
+				return Event{Button: button}
+			}
+		case ' ':
+			return Event{Button: ButtonAction}
+		default:
+			if key, ok := keyMap[unicode.ToLower(rune(b))]; ok {
+				return Event{Key: key}
+			}
+		}
+	}
+}
+
+// readArrowKey reads the rest of an arrow key escape sequence after ESC. The
+// whole sequence arrives at once, so a lone ESC doesn't wait for more input.
+func (ansi *ANSI) readArrowKey() (Button, bool) {
+	if ansi.reader.Buffered() < 2 {
+		return NoButton, false
+	}
+
+	sequence, _ := ansi.reader.Peek(2)
+	button, ok := arrowKeys[sequence[1]]
+
+	if !ok || (sequence[0] != '[' && sequence[0] != 'O') {
+		return NoButton, false
+	}
+
+	ansi.reader.Discard(2)
+
+	return button, true
+}
+
+// skipTelnetCommand reads the rest of a telnet command after IAC.
+func (ansi *ANSI) skipTelnetCommand() error {
+	command, err := ansi.reader.ReadByte()
+
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+		_, err = ansi.reader.ReadByte()
+	case telnetSB:
+		previous := byte(0)
+
+		for {
+			b, err := ansi.reader.ReadByte()
+
+			if err != nil {
+				return err
+			}
+
+			if previous == telnetIAC && b == telnetSE {
+				return nil
+			}
+
+			previous = b
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stream reads from input and records what is written.
+type stream struct {
+	input  *bytes.Buffer
+	output bytes.Buffer
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	return s.input.Read(p)
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	return s.output.Write(p)
+}
+
+func TestNewANSI(t *testing.T) {
+	ansi, err := NewANSI(&stream{}, Config{Colors: "256"})
+	assert.Nil(t, err)
+	assert.Equal(t, ansi.Mode, ModeHalf)
+
+	_, err = NewANSI(&stream{}, Config{Colors: "4096"})
+	assert.EqualError(t, err, "Unknown color mode: 4096")
+}
+
+func TestANSIPollEvent(t *testing.T) {
+	input := []byte{
+		telnetIAC, telnetDO, telnetEcho,
+		'W',
+		telnetIAC, telnetSB, 31, 0, 80, 0, 24, telnetIAC, telnetSE,
+		'\r', '\n', 'x',
+		'\x1b', '[', 'D', ' ',
+		ctrlC,
+	}
+	ansi, err := NewANSI(&stream{input: bytes.NewBuffer(input)}, Config{Colors: "16"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, ansi.PollEvent(), Event{Key: 0x05})
+	assert.Equal(t, ansi.PollEvent(), Event{Key: 0x00})
+	assert.Equal(t, ansi.PollEvent(), Event{Button: ButtonLeft})
+	assert.Equal(t, ansi.PollEvent(), Event{Button: ButtonAction})
+	assert.Equal(t, ansi.PollEvent(), Event{Quit: true})
+
+	// The stream is closed
+	assert.Equal(t, ansi.PollEvent(), Event{Quit: true})
+}
+
+func TestANSIRender(t *testing.T) {
+	s := &stream{}
+	ansi, err := NewANSI(s, Config{Mode: ModeHalf, Palette: Themes["white"], Colors: "16"})
+	assert.Nil(t, err)
+
+	frame := [width * height]byte{}
+	frame[1] = pixelOn
+
+	ansi.Render(&frame)
+
+	output := s.output.String()
+	// Every row is written, changing colors only when they differ
+	assert.Equal(t, strings.Count(output, "\x1b["), 16+18+1)
+	assert.True(t, strings.HasPrefix(output, "\x1b[1;1H\x1b[30;40m▀\x1b[37;40m▀\x1b[30;40m▀▀"))
+	assert.True(t, strings.HasSuffix(output, "\x1b[16;1H\x1b[30;40m"+strings.Repeat("▀", 64)+"\x1b[0m"))
+
+	// Only the changed row is written
+	s.output.Reset()
+	frame[3*width] = pixelOn
+	ansi.Render(&frame)
+
+	assert.Equal(t, s.output.String(), "\x1b[2;1H\x1b[30;47m▀\x1b[30;40m"+strings.Repeat("▀", 63)+"\x1b[0m")
+
+	s.output.Reset()
+	ansi.Render(&frame)
+
+	assert.Equal(t, s.output.String(), "")
+}
+
+func TestANSIColors(t *testing.T) {
+	ansi := ANSI{Colors: "256"}
+	assert.Equal(t, ansi.sgr(Color{0xFF, 0x00, 0x00}, Color{}), "\x1b[38;5;196;48;5;16m")
+
+	ansi = ANSI{Colors: "truecolor"}
+	assert.Equal(t, ansi.sgr(Color{0xFF, 0xB0, 0x00}, Color{}), "\x1b[38;2;255;176;0;48;2;0;0;0m")
+}
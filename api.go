@@ -0,0 +1,309 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxStepCycles bounds a single step request, which holds the VM for every
+// other request while it runs.
+const maxStepCycles = 1000000
+
+func init() {
+	commands["api"] = serveAPI
+}
+
+// serveAPI runs a headless VM driven through HTTP requests.
+func serveAPI(args []string) error {
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "Address to listen on")
+	path := flags.String("rom", "", "Path to a chip8 rom to load at start")
+	flags.Parse(args)
+
+	api := NewAPI()
+
+	if *path != "" {
+		rom, err := readROMFile(*path)
+
+		if err != nil {
+			return err
+		}
+
+		if err := api.Load(rom); err != nil {
+			return err
+		}
+	}
+
+	log.Println("Serving the API on", *addr)
+
+	return http.ListenAndServe(*addr, api)
+}
+
+// API exposes a headless VM over HTTP, with JSON requests and responses:
+//
+//	POST /rom                 Load the ROM in the body and reset the VM
+//	POST /step?cycles=N       Run N instructions (1 by default, at most 1000000)
+//	POST /keys/{key}/press    Press a key, 0-F
+//	POST /keys/{key}/release  Release a key
+//	GET  /registers           Read V0-VF, I, PC, SP, the stack and timers
+//	GET  /memory?address=A&length=N
+//	GET  /screen              Read the framebuffer as 0/1 pixels
+//	GET  /screen.png?scale=N  Read the framebuffer as a PNG
+//	GET  /state               Save the state of the VM
+//	PUT  /state               Restore a state returned by GET /state
+type API struct {
+	mutex sync.Mutex
+	vm    *VM
+	mux   *http.ServeMux
+}
+
+// Registers is the JSON representation of the registers of the VM.
+type Registers struct {
+	V     [16]uint8
+	I     uint16
+	PC    uint16
+	SP    uint8
+	Stack [16]uint16
+	DT    uint8
+	ST    uint8
+}
+
+func NewAPI() *API {
+	api := &API{vm: InitHeadlessVM(), mux: http.NewServeMux()}
+
+	api.handle("/rom", http.MethodPost, api.loadROM)
+	api.handle("/step", http.MethodPost, api.step)
+	api.handle("/keys/", http.MethodPost, api.key)
+	api.handle("/registers", http.MethodGet, api.registers)
+	api.handle("/memory", http.MethodGet, api.memory)
+	api.handle("/screen", http.MethodGet, api.screen)
+	api.handle("/screen.png", http.MethodGet, api.screenPNG)
+	api.mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		api.mutex.Lock()
+		defer api.mutex.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, api.vm.SaveState())
+		case http.MethodPut:
+			api.restoreState(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, errors.New("Use GET or PUT"))
+		}
+	})
+
+	return api
+}
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mux.ServeHTTP(w, r)
+}
+
+// Load resets the VM and loads the program.
+func (api *API) Load(rom *ROM) error {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	return api.load(rom)
+}
+
+func (api *API) load(rom *ROM) error {
+	info, _ := LookupROM(rom)
+	vm := InitHeadlessVM()
+	vm.Configure(info)
+
+	if err := vm.LoadProgram(rom.Program); err != nil {
+		return err
+	}
+
+	api.vm = vm
+
+	return nil
+}
+
+// handle registers a handler for a single method, run with the VM locked.
+func (api *API) handle(pattern, method string, handler http.HandlerFunc) {
+	api.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Use %s", method))
+			return
+		}
+
+		api.mutex.Lock()
+		defer api.mutex.Unlock()
+
+		handler(w, r)
+	})
+}
+
+func (api *API) loadROM(w http.ResponseWriter, r *http.Request) {
+	rom, err := ReadROM(r.Body, "")
+
+	if err == nil {
+		err = api.load(rom)
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	api.registers(w, r)
+}
+
+func (api *API) step(w http.ResponseWriter, r *http.Request) {
+	cycles := 1
+
+	if value := r.URL.Query().Get("cycles"); value != "" {
+		var err error
+
+		if cycles, err = strconv.Atoi(value); err != nil || cycles < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid cycles: %s", value))
+			return
+		}
+
+		if cycles > maxStepCycles {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Too many cycles: %d, at most %d run in a request", cycles, maxStepCycles))
+			return
+		}
+	}
+
+	for i := 0; i < cycles; i++ {
+		if err := api.vm.Step(); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+
+	api.registers(w, r)
+}
+
+func (api *API) key(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/keys/"), "/")
+	key, err := strconv.ParseUint(parts[0], 16, 4)
+
+	if err != nil || len(parts) != 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown key: %s", r.URL.Path))
+		return
+	}
+
+	switch parts[1] {
+	case "press":
+		api.vm.Keypad.PressKey(uint8(key))
+	case "release":
+		api.vm.Keypad.ReleaseKey(uint8(key))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown key action: %s", parts[1]))
+		return
+	}
+
+	writeJSON(w, api.vm.Keypad.keys)
+}
+
+func (api *API) registers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, Registers{
+		V:     api.vm.V,
+		I:     api.vm.I,
+		PC:    api.vm.PC,
+		SP:    api.vm.SP,
+		Stack: api.vm.Stack,
+		DT:    api.vm.DT,
+		ST:    api.vm.ST,
+	})
+}
+
+func (api *API) memory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	address, err := strconv.ParseUint(query.Get("address"), 0, 16)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid address: %s", query.Get("address")))
+		return
+	}
+
+	length := uint64(1)
+
+	if value := query.Get("length"); value != "" {
+		if length, err = strconv.ParseUint(value, 0, 16); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid length: %s", value))
+			return
+		}
+	}
+
+	if address+length > uint64(len(api.vm.Memory)) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Out of memory: %d bytes at 0x%X", length, address))
+		return
+	}
+
+	data := []int{}
+	for _, value := range api.vm.Memory[address : address+length] {
+		data = append(data, int(value))
+	}
+
+	writeJSON(w, map[string]interface{}{"Address": address, "Data": data})
+}
+
+func (api *API) screen(w http.ResponseWriter, r *http.Request) {
+	pixels := []int{}
+	for _, pixel := range api.vm.Screen.Pixels {
+		pixels = append(pixels, int(pixel))
+	}
+
+	writeJSON(w, map[string]interface{}{"Width": width, "Height": height, "Pixels": pixels})
+}
+
+func (api *API) screenPNG(w http.ResponseWriter, r *http.Request) {
+	scale := 1
+
+	if value := r.URL.Query().Get("scale"); value != "" {
+		var err error
+
+		if scale, err = strconv.Atoi(value); err != nil || scale < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid scale: %s", value))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, api.vm.Screen.Image(Themes["white"], scale))
+}
+
+func (api *API) restoreState(w http.ResponseWriter, r *http.Request) {
+	state := State{}
+
+	err := json.NewDecoder(r.Body).Decode(&state)
+
+	if err == nil {
+		err = state.Validate()
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	api.vm.RestoreState(state)
+	api.registers(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
+}
@@ -0,0 +1,150 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func apiRequest(api *API, method, url string, body []byte) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	api.ServeHTTP(recorder, httptest.NewRequest(method, url, bytes.NewReader(body)))
+
+	return recorder
+}
+
+func TestAPIStep(t *testing.T) {
+	api := NewAPI()
+
+	// LD V0, 0x05 then LD V1, 0x07
+	response := apiRequest(api, "POST", "/rom", []byte{0x60, 0x05, 0x61, 0x07})
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	response = apiRequest(api, "POST", "/step?cycles=2", nil)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	registers := Registers{}
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &registers))
+	assert.Equal(t, registers.PC, uint16(0x204))
+	assert.Equal(t, registers.V[0], uint8(0x05))
+	assert.Equal(t, registers.V[1], uint8(0x07))
+
+	response = apiRequest(api, "POST", "/step?cycles=1000001", nil)
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	assert.Equal(t, api.vm.PC, uint16(0x204))
+
+	response = apiRequest(api, "POST", "/step", nil)
+	assert.Equal(t, response.Code, http.StatusUnprocessableEntity)
+
+	response = apiRequest(api, "POST", "/rom", nil)
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+
+	response = apiRequest(api, "GET", "/step", nil)
+	assert.Equal(t, response.Code, http.StatusMethodNotAllowed)
+}
+
+func TestAPILoadKnownROM(t *testing.T) {
+	rom, err := readROMFile("roms/breakout.ch8")
+	assert.Nil(t, err)
+
+	api := NewAPI()
+	assert.Nil(t, api.Load(rom))
+	assert.Equal(t, api.vm.Quirks, QuirksVIP)
+	assert.Equal(t, api.vm.TickRate, 420)
+}
+
+func TestAPIKeys(t *testing.T) {
+	api := NewAPI()
+
+	response := apiRequest(api, "POST", "/keys/a/press", nil)
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, api.vm.Keypad.CheckPressed(0xA), true)
+
+	response = apiRequest(api, "POST", "/keys/A/release", nil)
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, api.vm.Keypad.CheckPressed(0xA), false)
+
+	response = apiRequest(api, "POST", "/keys/10/press", nil)
+	assert.Equal(t, response.Code, http.StatusNotFound)
+}
+
+func TestAPIMemory(t *testing.T) {
+	api := NewAPI()
+	api.Load(&ROM{Program: []byte{0x12, 0x34}})
+
+	response := apiRequest(api, "GET", "/memory?address=0x200&length=2", nil)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	memory := struct{ Data []int }{}
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &memory))
+	assert.Equal(t, memory.Data, []int{0x12, 0x34})
+
+	response = apiRequest(api, "GET", "/memory?address=4095&length=2", nil)
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+}
+
+func TestAPIScreen(t *testing.T) {
+	api := NewAPI()
+
+	// Draw the font sprite of 0
+	api.Load(&ROM{Program: []byte{0xD0, 0x05}})
+	apiRequest(api, "POST", "/step", nil)
+
+	response := apiRequest(api, "GET", "/screen", nil)
+	screen := struct {
+		Width, Height int
+		Pixels        []int
+	}{}
+	assert.Nil(t, json.Unmarshal(response.Body.Bytes(), &screen))
+	assert.Equal(t, screen.Width, width)
+	assert.Equal(t, screen.Pixels[0:5], []int{1, 1, 1, 1, 0})
+
+	response = apiRequest(api, "GET", "/screen.png?scale=2", nil)
+	assert.Equal(t, response.Header().Get("Content-Type"), "image/png")
+
+	img, err := png.Decode(response.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, img.Bounds().Dx(), width*2)
+}
+
+func TestAPIState(t *testing.T) {
+	api := NewAPI()
+	api.Load(&ROM{Program: []byte{0x60, 0x05, 0x60, 0x06}})
+	apiRequest(api, "POST", "/step", nil)
+
+	state := apiRequest(api, "GET", "/state", nil).Body.Bytes()
+	apiRequest(api, "POST", "/step", nil)
+	assert.Equal(t, api.vm.V[0], uint8(0x06))
+
+	response := apiRequest(api, "PUT", "/state", state)
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, api.vm.V[0], uint8(0x05))
+	assert.Equal(t, api.vm.PC, uint16(0x202))
+
+	response = apiRequest(api, "PUT", "/state", []byte(`{"PC": 4095}`))
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	assert.Equal(t, api.vm.PC, uint16(0x202))
+}
+
+func TestAPIStackOverflow(t *testing.T) {
+	api := NewAPI()
+
+	// CALL 0x200
+	api.Load(&ROM{Program: []byte{0x22, 0x00}})
+
+	response := apiRequest(api, "POST", "/step?cycles=15", nil)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	response = apiRequest(api, "POST", "/step", nil)
+	assert.Equal(t, response.Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, response.Body.String(), "{\"Error\":\"Stack overflow: CALL at 200\"}\n")
+	assert.Equal(t, api.vm.SP, uint8(15))
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"syscall/js"
+)
+
+// Canvas draws the screen on an HTML canvas, reads keys from the page and
+// plays the buzzer through WebAudio.
+type Canvas struct {
+	Palette Palette
+
+	element js.Value
+	context js.Value
+	image   js.Value // ImageData the frame is drawn into
+	pixels  []byte
+	events  chan Event
+
+	audio js.Value // AudioContext, created on the first key press
+	gain  js.Value // Volume of the buzzer oscillator
+}
+
+// NewCanvas returns a frontend drawing on the given canvas element, which is
+// resized to the CHIP-8 screen and should be scaled up with CSS.
+func NewCanvas(element js.Value, palette Palette) *Canvas {
+	return &Canvas{
+		Palette: palette,
+		element: element,
+		pixels:  make([]byte, width*height*4),
+		events:  make(chan Event, 32),
+	}
+}
+
+func (canvas *Canvas) Init() error {
+	canvas.element.Set("width", width)
+	canvas.element.Set("height", height)
+	canvas.context = canvas.element.Call("getContext", "2d")
+	canvas.image = canvas.context.Call("createImageData", width, height)
+
+	document := js.Global().Get("document")
+	document.Call("addEventListener", "keydown", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		canvas.resumeAudio()
+		canvas.onKey(args[0], false)
+		return nil
+	}))
+	document.Call("addEventListener", "keyup", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		canvas.onKey(args[0], true)
+		return nil
+	}))
+
+	canvas.Render(&[width * height]byte{})
+
+	return nil
+}
+
+func (canvas *Canvas) Close() {}
+
+func (canvas *Canvas) Run(loop func() error) error {
+	return loop()
+}
+
+func (canvas *Canvas) Render(frame *[width * height]byte) {
+	for i, intensity := range frame {
+		pixel := canvas.Palette.Shade(intensity)
+		canvas.pixels[i*4] = pixel.R
+		canvas.pixels[i*4+1] = pixel.G
+		canvas.pixels[i*4+2] = pixel.B
+		canvas.pixels[i*4+3] = 0xFF
+	}
+
+	js.CopyBytesToJS(canvas.image.Get("data"), canvas.pixels)
+	canvas.context.Call("putImageData", canvas.image, 0, 0)
+}
+
+func (canvas *Canvas) PollEvent() Event {
+	return <-canvas.events
+}
+
+func (canvas *Canvas) ReportsReleases() bool {
+	return true
+}
+
+// canvasButtons are the buttons by the key names of keyboard events.
+var canvasButtons = map[string]Button{
+	"arrowup":    ButtonUp,
+	"arrowdown":  ButtonDown,
+	"arrowleft":  ButtonLeft,
+	"arrowright": ButtonRight,
+	" ":          ButtonAction,
+}
+
+func (canvas *Canvas) onKey(event js.Value, released bool) {
+	if event.Get("repeat").Bool() {
+		return
+	}
+
+	name := []rune(strings.ToLower(event.Get("key").String()))
+	result := Event{Released: released}
+
+	if button, ok := canvasButtons[string(name)]; ok {
+		result.Button = button
+	} else if len(name) != 1 {
+		return
+	} else if key, ok := keyMap[name[0]]; ok {
+		result.Key = key
+	} else {
+		return
+	}
+
+	event.Call("preventDefault")
+
+	select {
+	case canvas.events <- result:
+	default:
+	}
+}
+
+// resumeAudio sets up WebAudio, which browsers only allow after the user
+// interacted with the page.
+func (canvas *Canvas) resumeAudio() {
+	if !canvas.audio.IsUndefined() {
+		canvas.audio.Call("resume")
+		return
+	}
+
+	constructor := js.Global().Get("AudioContext")
+	if constructor.IsUndefined() {
+		return
+	}
+
+	canvas.audio = constructor.New()
+
+	oscillator := canvas.audio.Call("createOscillator")
+	oscillator.Set("type", "square")
+	oscillator.Get("frequency").Set("value", 440)
+
+	canvas.gain = canvas.audio.Call("createGain")
+	canvas.gain.Get("gain").Set("value", 0)
+
+	oscillator.Call("connect", canvas.gain)
+	canvas.gain.Call("connect", canvas.audio.Get("destination"))
+	oscillator.Call("start")
+}
+
+func (canvas *Canvas) Beep(on bool) {
+	if canvas.gain.IsUndefined() {
+		return
+	}
+
+	volume := 0.0
+	if on {
+		volume = 0.1
+	}
+
+	canvas.gain.Get("gain").Set("value", volume)
+}
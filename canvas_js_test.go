@@ -0,0 +1,55 @@
+package main
+
+import (
+	"syscall/js"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests need a DOM, so they only run in a browser, for example with
+// wasmbrowsertest.
+func newTestCanvas(t *testing.T) *Canvas {
+	document := js.Global().Get("document")
+
+	if document.IsUndefined() {
+		t.Skip("No DOM to draw on")
+	}
+
+	canvas := NewCanvas(document.Call("createElement", "canvas"), Themes["amber"])
+	assert.Nil(t, canvas.Init())
+
+	return canvas
+}
+
+func TestCanvasRender(t *testing.T) {
+	canvas := newTestCanvas(t)
+
+	frame := [width * height]byte{}
+	frame[width+2] = pixelOn
+	canvas.Render(&frame)
+
+	data := canvas.context.Call("getImageData", 0, 0, width, height).Get("data")
+	pixel := func(x, y int) []int {
+		i := (y*width + x) * 4
+		return []int{data.Index(i).Int(), data.Index(i + 1).Int(), data.Index(i + 2).Int()}
+	}
+
+	assert.Equal(t, pixel(2, 1), []int{0xFF, 0xB0, 0x00})
+	assert.Equal(t, pixel(1, 1), []int{0x00, 0x00, 0x00})
+}
+
+func TestCanvasKeys(t *testing.T) {
+	canvas := newTestCanvas(t)
+
+	keyboardEvent := js.Global().Get("KeyboardEvent")
+	document := js.Global().Get("document")
+
+	document.Call("dispatchEvent", keyboardEvent.New("keydown", map[string]interface{}{"key": "W"}))
+	document.Call("dispatchEvent", keyboardEvent.New("keyup", map[string]interface{}{"key": "w"}))
+	document.Call("dispatchEvent", keyboardEvent.New("keydown", map[string]interface{}{"key": "Shift"}))
+
+	assert.Equal(t, canvas.PollEvent(), Event{Key: 0x05})
+	assert.Equal(t, canvas.PollEvent(), Event{Key: 0x05, Released: true})
+	assert.Equal(t, len(canvas.events), 0)
+}
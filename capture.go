@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Image returns the framebuffer as a paletted image, drawing every pixel as a
+// scale x scale square in the colors of the palette.
+func (screen *Screen) Image(palette Palette, scale int) *image.Paletted {
+	colors := color.Palette{}
+
+	for _, c := range palette.colors() {
+		colors = append(colors, color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xFF})
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), colors)
+
+	for y := 0; y < height*scale; y++ {
+		for x := 0; x < width*scale; x++ {
+			img.SetColorIndex(x, y, uint8(palette.planeIndex(screen.Pixels[(y/scale)*width+x/scale])))
+		}
+	}
+
+	return img
+}
+
+// SavePNG writes the image to path as a PNG.
+func SavePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// CapturePath returns a file name in dir for a capture of the ROM taken now.
+func CapturePath(dir, romPath, extension string) string {
+	name := strings.TrimSuffix(filepath.Base(romPath), filepath.Ext(romPath))
+	stamp := time.Now().Format("20060102-150405.000")
+
+	return filepath.Join(dir, fmt.Sprintf("%s-%s.%s", name, stamp, extension))
+}
+
+// Shortest delay between GIF frames, in 100ths of a second. Viewers slow
+// shorter frames down to 10 hundredths.
+const minFrameDelay = 2
+
+// Recorder records the screen as an animated GIF. Frames are written to Path
+// as they come, so that long recordings don't pile up in memory. Identical
+// frames are merged into a single longer one, and a frame shorter than
+// minFrameDelay is replaced by the next one, which plays at up to 50fps.
+type Recorder struct {
+	Path    string
+	Palette Palette
+	Scale   int
+
+	file    *os.File
+	writer  *bufio.Writer
+	err     error // First error writing the file, returned by Save
+	frames  int   // Frames of 1/60th of a second recorded so far
+	pending [width * height]byte
+	start   int // Frame the pending one was first shown at
+	started bool
+}
+
+func NewRecorder(path string, palette Palette, scale int) *Recorder {
+	return &Recorder{Path: path, Palette: palette, Scale: scale}
+}
+
+// Frame records the current contents of the screen. A frame is written once
+// the next different one shows how long it lasted.
+func (recorder *Recorder) Frame(screen *Screen) {
+	switch {
+	case !recorder.started:
+		recorder.started = true
+		recorder.pending = screen.Pixels
+		recorder.start = recorder.frames
+	case screen.Pixels == recorder.pending:
+	case recorder.delay() < minFrameDelay:
+		recorder.pending = screen.Pixels
+	default:
+		recorder.write(recorder.delay())
+		recorder.pending = screen.Pixels
+		recorder.start = recorder.frames
+	}
+
+	recorder.frames++
+}
+
+// delay returns how long the pending frame has been shown, in 100ths of a
+// second. Going through the time of both ends keeps rounding errors from
+// adding up.
+func (recorder *Recorder) delay() int {
+	return recorder.frames*100/int(frameSpeed) - recorder.start*100/int(frameSpeed)
+}
+
+// Save writes the last frame and closes the file.
+func (recorder *Recorder) Save() error {
+	if !recorder.started {
+		return nil
+	}
+
+	delay := recorder.delay()
+	if delay < minFrameDelay {
+		delay = minFrameDelay
+	}
+
+	recorder.write(delay)
+
+	if recorder.err == nil {
+		recorder.writer.WriteByte(0x3B) // Trailer
+		recorder.err = recorder.writer.Flush()
+	}
+
+	if recorder.file != nil {
+		if err := recorder.file.Close(); recorder.err == nil {
+			recorder.err = err
+		}
+	}
+
+	return recorder.err
+}
+
+// write appends the pending frame to the file, creating it with the header
+// on the first frame.
+func (recorder *Recorder) write(delay int) {
+	if recorder.err != nil {
+		return
+	}
+
+	screen := Screen{Pixels: recorder.pending}
+	img := screen.Image(recorder.Palette, recorder.Scale)
+
+	if recorder.file == nil {
+		if recorder.file, recorder.err = os.Create(recorder.Path); recorder.err != nil {
+			return
+		}
+
+		recorder.writer = bufio.NewWriter(recorder.file)
+		writeGIFHeader(recorder.writer, img)
+	}
+
+	recorder.err = writeGIFFrame(recorder.writer, img, delay)
+}
+
+// gifColorBits returns the number of bits of the color indexes of the palette.
+func gifColorBits(palette color.Palette) int {
+	bits := 1
+
+	for 1<<uint(bits) < len(palette) {
+		bits++
+	}
+
+	return bits
+}
+
+// writeGIFHeader writes the GIF header with the palette of the image as the
+// global color table, and makes the animation loop.
+func writeGIFHeader(writer *bufio.Writer, img *image.Paletted) {
+	bits := gifColorBits(img.Palette)
+	size := img.Bounds().Size()
+
+	writer.WriteString("GIF89a")
+	binary.Write(writer, binary.LittleEndian, [2]uint16{uint16(size.X), uint16(size.Y)})
+	writer.Write([]byte{0x80 | byte(bits-1)<<4 | byte(bits-1), 0, 0})
+
+	for i := 0; i < 1<<uint(bits); i++ {
+		r, g, b := uint32(0), uint32(0), uint32(0)
+		if i < len(img.Palette) {
+			r, g, b, _ = img.Palette[i].RGBA()
+		}
+
+		writer.Write([]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)})
+	}
+
+	writer.Write([]byte{0x21, 0xFF, 0x0B})
+	writer.WriteString("NETSCAPE2.0")
+	writer.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+// writeGIFFrame writes an image shown for delay 100ths of a second, with its
+// pixels compressed in sub-blocks of up to 255 bytes.
+func writeGIFFrame(writer *bufio.Writer, img *image.Paletted, delay int) error {
+	size := img.Bounds().Size()
+
+	writer.Write([]byte{0x21, 0xF9, 0x04, 0x00, byte(delay), byte(delay >> 8), 0x00, 0x00})
+	writer.WriteByte(0x2C)
+	binary.Write(writer, binary.LittleEndian, [4]uint16{0, 0, uint16(size.X), uint16(size.Y)})
+	writer.WriteByte(0x00)
+
+	literalWidth := gifColorBits(img.Palette)
+	if literalWidth < 2 {
+		literalWidth = 2
+	}
+
+	compressed := bytes.Buffer{}
+	encoder := lzw.NewWriter(&compressed, lzw.LSB, literalWidth)
+
+	if _, err := encoder.Write(img.Pix); err != nil {
+		return err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	writer.WriteByte(byte(literalWidth))
+
+	for data := compressed.Bytes(); len(data) > 0; {
+		block := data
+		if len(block) > 255 {
+			block = block[:255]
+		}
+
+		writer.WriteByte(byte(len(block)))
+		writer.Write(block)
+		data = data[len(block):]
+	}
+
+	return writer.WriteByte(0x00)
+}
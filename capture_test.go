@@ -0,0 +1,107 @@
+package main
+
+import (
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenImage(t *testing.T) {
+	screen := Screen{}
+	screen.Pixels[width+1] = 1
+
+	img := screen.Image(Themes["amber"], 2)
+
+	assert.Equal(t, img.Bounds().Dx(), 128)
+	assert.Equal(t, img.Bounds().Dy(), 64)
+	assert.Equal(t, img.ColorIndexAt(1, 1), uint8(0))
+	assert.Equal(t, img.ColorIndexAt(2, 2), uint8(1))
+	assert.Equal(t, img.ColorIndexAt(3, 3), uint8(1))
+	assert.Equal(t, img.ColorIndexAt(4, 3), uint8(0))
+	assert.Equal(t, img.At(2, 2), color.RGBA{R: 0xFF, G: 0xB0, B: 0x00, A: 0xFF})
+}
+
+func TestSavePNG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	screen := Screen{}
+	screen.Pixels[0] = 1
+	path := filepath.Join(dir, "screenshot.png")
+
+	err = SavePNG(path, screen.Image(Themes["white"], 1))
+	assert.Nil(t, err)
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	assert.Nil(t, err)
+
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, []uint32{r, g, b}, []uint32{0xFFFF, 0xFFFF, 0xFFFF})
+	r, g, b, _ = img.At(1, 0).RGBA()
+	assert.Equal(t, []uint32{r, g, b}, []uint32{0, 0, 0})
+}
+
+func TestCapturePath(t *testing.T) {
+	path := CapturePath("shots", "./roms/breakout.ch8", "png")
+
+	assert.True(t, strings.HasPrefix(path, filepath.Join("shots", "breakout-")))
+	assert.True(t, strings.HasSuffix(path, ".png"))
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	screen := Screen{}
+	recorder := NewRecorder(filepath.Join(dir, "session.gif"), Themes["green"], 2)
+
+	recorder.Frame(&screen)
+	recorder.Frame(&screen)
+	recorder.Frame(&screen)
+
+	screen.Pixels[0] = 1
+	recorder.Frame(&screen)
+
+	// Frames changing every 1/60th of a second are merged to last at least 2/100ths
+	for i := 0; i < 6; i++ {
+		screen.Pixels[1] ^= 1
+		recorder.Frame(&screen)
+	}
+
+	assert.Nil(t, recorder.Save())
+
+	file, err := os.Open(recorder.Path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	animation, err := gif.DecodeAll(file)
+	assert.Nil(t, err)
+	assert.Equal(t, animation.Delay, []int{5, 3, 2, 3, 2, 2})
+	assert.Equal(t, animation.LoopCount, 0)
+	assert.Equal(t, animation.Image[0].Bounds().Dx(), width*2)
+	assert.Equal(t, animation.Image[0].ColorIndexAt(0, 0), uint8(0))
+	assert.Equal(t, animation.Image[1].ColorIndexAt(1, 1), uint8(1))
+	assert.Equal(t, animation.Image[1].At(0, 0), animation.Image[0].Palette[1])
+}
+
+func TestRecorderEmpty(t *testing.T) {
+	recorder := NewRecorder(filepath.Join(os.TempDir(), "chip8-never-written.gif"), Themes["green"], 1)
+
+	assert.Nil(t, recorder.Save())
+
+	_, err := os.Stat(recorder.Path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/gif"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Cartridge is the payload of an Octo cartridge, a GIF showing a label that
+// hides the source code of a program and the options it runs with.
+type Cartridge struct {
+	Program string           `json:"program"`
+	Options CartridgeOptions `json:"options"`
+}
+
+// CartridgeOptions are the Octo options of a cartridge.
+type CartridgeOptions struct {
+	TickRate        int    `json:"tickrate"`
+	MaxSize         int    `json:"maxSize"`
+	FillColor       string `json:"fillColor"`
+	BackgroundColor string `json:"backgroundColor"`
+	ShiftQuirks     bool   `json:"shiftQuirks"`
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"`
+	JumpQuirks      bool   `json:"jumpQuirks"`
+	LogicQuirks     bool   `json:"logicQuirks"`
+	ClipQuirks      bool   `json:"clipQuirks"`
+	VBlankQuirks    bool   `json:"vBlankQuirks"`
+}
+
+// Platform returns the platform the program was written for, which Octo
+// only records as the largest program size it allows: 3215 bytes for
+// CHIP-8, 3583 for SCHIP and 65024 for XO-CHIP.
+func (options CartridgeOptions) Platform() Platform {
+	switch {
+	case options.MaxSize > 3583:
+		return PlatformXOCHIP
+	case options.MaxSize > 3215:
+		return PlatformSCHIP
+	}
+
+	return PlatformCHIP8
+}
+
+// Bytecode returns the program of a cartridge whose source is nothing but
+// byte literals, labels and comments, which is how ROMs are usually turned
+// into cartridges when there is no source to share, along with the symbols of
+// its labels and lines. Lines are named after the file the cartridge was read
+// from. Any other source has to be assembled with Octo.
+func (cartridge *Cartridge) Bytecode(name string) ([]byte, *Symbols, error) {
+	program := []byte{}
+	symbols := &Symbols{lines: map[uint16]string{}}
+
+	for number, line := range strings.Split(cartridge.Program, "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+
+		tokens := strings.Fields(line)
+
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+
+			if token == ":" && i+1 < len(tokens) {
+				// Octo starts at main, so it has to come first
+				if tokens[i+1] == "main" && len(program) > 0 {
+					return nil, nil, fmt.Errorf("Line %d: main doesn't start the program", number+1)
+				}
+
+				i++
+				symbols.labels = append(symbols.labels, Symbol{Address: uint16(programStart + len(program)), Name: tokens[i]})
+				continue
+			}
+
+			value, err := strconv.ParseInt(token, 0, 16)
+
+			if err != nil || value < -128 || value > 255 {
+				return nil, nil, fmt.Errorf("Line %d: %s isn't a byte", number+1, token)
+			}
+
+			symbols.lines[uint16(programStart+len(program))] = fmt.Sprintf("%s:%d", name, number+1)
+			program = append(program, byte(value))
+		}
+	}
+
+	return program, symbols, nil
+}
+
+// DecodeCartridge reads the payload of an Octo cartridge. It is stored two
+// bits at a time in the low bits of the color index of every pixel of every
+// frame, most significant first, as a 32 bit length followed by JSON.
+func DecodeCartridge(reader io.Reader) (*Cartridge, error) {
+	images, err := gif.DecodeAll(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cartridgeFromGIF(images)
+}
+
+// cartridgeFromGIF decodes the cartridge stored in the frames of a GIF.
+func cartridgeFromGIF(images *gif.GIF) (*Cartridge, error) {
+	payload := []byte{}
+	value, bits := byte(0), 0
+
+	for _, frame := range images.Image {
+		bounds := frame.Bounds()
+
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				value = value<<2 | frame.ColorIndexAt(x, y)&3
+				bits += 2
+
+				if bits == 8 {
+					payload = append(payload, value)
+					value, bits = 0, 0
+				}
+			}
+		}
+	}
+
+	if len(payload) < 4 || uint64(binary.BigEndian.Uint32(payload)) > uint64(len(payload)-4) {
+		return nil, errors.New("Not an Octo cartridge")
+	}
+
+	cartridge := &Cartridge{}
+	size := binary.BigEndian.Uint32(payload)
+
+	if err := json.Unmarshal(payload[4:4+size], cartridge); err != nil {
+		return nil, errors.New("Not an Octo cartridge")
+	}
+
+	return cartridge, nil
+}
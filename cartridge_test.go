@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeCartridge hides a payload in the low bits of a GIF the way Octo does,
+// over as many 32x32 frames as it takes.
+func encodeCartridge(t *testing.T, cartridge Cartridge) []byte {
+	data, err := json.Marshal(cartridge)
+	assert.Nil(t, err)
+
+	payload := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(payload, uint32(len(data)))
+	payload = append(payload, data...)
+
+	palette := color.Palette{}
+	for i := 0; i < 16; i++ {
+		palette = append(palette, color.Gray{uint8(i * 16)})
+	}
+
+	images := &gif.GIF{}
+	frame := image.NewPaletted(image.Rect(0, 0, 32, 32), palette)
+
+	for i := 0; i < len(payload)*4; i++ {
+		if i > 0 && i%len(frame.Pix) == 0 {
+			images.Image = append(images.Image, frame)
+			images.Delay = append(images.Delay, 0)
+			frame = image.NewPaletted(frame.Rect, palette)
+		}
+
+		// The label takes the high bits
+		frame.Pix[i%len(frame.Pix)] = 0xC | payload[i/4]>>(6-2*(i%4))&3
+	}
+
+	images.Image = append(images.Image, frame)
+	images.Delay = append(images.Delay, 0)
+
+	buf := bytes.Buffer{}
+	assert.Nil(t, gif.EncodeAll(&buf, images))
+
+	return buf.Bytes()
+}
+
+func TestDecodeCartridge(t *testing.T) {
+	source := ": main\n  loop again\n" + string(bytes.Repeat([]byte("# Long enough for two frames\n"), 20))
+	data := encodeCartridge(t, Cartridge{
+		Program: source,
+		Options: CartridgeOptions{TickRate: 20, MaxSize: 3583, ShiftQuirks: true},
+	})
+
+	cartridge, err := DecodeCartridge(bytes.NewReader(data))
+
+	assert.Nil(t, err)
+	assert.Equal(t, cartridge.Program, source)
+	assert.Equal(t, cartridge.Options.TickRate, 20)
+	assert.Equal(t, cartridge.Options.ShiftQuirks, true)
+	assert.Equal(t, cartridge.Options.Platform(), PlatformSCHIP)
+
+	_, err = ReadROM(bytes.NewReader(data), "game.gif")
+	assert.Equal(t, err.Error(), "This Octo cartridge holds SCHIP source code, which needs to be assembled with Octo first")
+}
+
+func TestCartridgeBytecode(t *testing.T) {
+	source := "# Clears the screen and loops\n: main\n  0x00 0xE0 # CLS\n: loop\n  0b00010010 2\n"
+	data := encodeCartridge(t, Cartridge{Program: source, Options: CartridgeOptions{MaxSize: 3583}})
+
+	rom, err := ReadROM(bytes.NewReader(data), "game.gif")
+
+	assert.Nil(t, err)
+	assert.Equal(t, rom.Program, []byte{0x00, 0xE0, 0x12, 0x02})
+	assert.Equal(t, rom.Platform, PlatformSCHIP)
+	assert.Equal(t, rom.Symbols.Describe(0x201), "0x201 main+1 game.gif:3")
+	assert.Equal(t, rom.Symbols.Describe(0x202), "0x202 loop game.gif:5")
+	assert.Equal(t, rom.Symbols.Disassemble(0x1202), "JP loop")
+
+	_, _, err = (&Cartridge{Program: "0x00 0xE0\n: main\n"}).Bytecode("game.gif")
+	assert.Equal(t, err.Error(), "Line 2: main doesn't start the program")
+
+	_, _, err = (&Cartridge{Program: ": main\n  0x100\n"}).Bytecode("game.gif")
+	assert.Equal(t, err.Error(), "Line 2: 0x100 isn't a byte")
+
+	_, err = ReadROM(bytes.NewReader(encodeCartridge(t, Cartridge{Program: "# Nothing yet\n"})), "empty.gif")
+	assert.Equal(t, err.Error(), "ROM is empty")
+}
+
+func TestDecodeCartridgeImage(t *testing.T) {
+	buf := bytes.Buffer{}
+	assert.Nil(t, gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Black, color.White}), nil))
+
+	_, err := DecodeCartridge(bytes.NewReader(buf.Bytes()))
+	assert.Equal(t, err.Error(), "Not an Octo cartridge")
+}
+
+func TestCartridgePlatform(t *testing.T) {
+	assert.Equal(t, CartridgeOptions{MaxSize: 3215}.Platform(), PlatformCHIP8)
+	assert.Equal(t, CartridgeOptions{MaxSize: 3583}.Platform(), PlatformSCHIP)
+	assert.Equal(t, CartridgeOptions{MaxSize: 65024}.Platform(), PlatformXOCHIP)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+)
+
+// Coverage counts how every address of memory was used while a ROM ran:
+// executed as code, read as data by DRW and Fx65, or written by Fx33 and Fx55.
+type Coverage struct {
+	Executed [memorySize]uint32
+	Read     [memorySize]uint32
+	Written  [memorySize]uint32
+}
+
+// Record counts the memory used by an instruction after it ran, meant to be
+// called from OnStep.
+func (coverage *Coverage) Record(vm *VM, pc, op uint16) {
+	coverage.add(&coverage.Executed, pc, 2)
+
+	x := op & 0x0F00 >> 8
+	i := vm.indexBefore(op)
+
+	switch {
+	case op&0xF000 == 0xD000: // DRW Vx, Vy, nibble
+		coverage.add(&coverage.Read, i, op&0x000F)
+	case op&0xF0FF == 0xF065: // LD Vx, [I]
+		coverage.add(&coverage.Read, i, x+1)
+	case op&0xF0FF == 0xF033: // LD B, Vx
+		coverage.add(&coverage.Written, i, 3)
+	case op&0xF0FF == 0xF055: // LD [I], Vx
+		coverage.add(&coverage.Written, i, x+1)
+	}
+}
+
+func (coverage *Coverage) add(counts *[memorySize]uint32, address, length uint16) {
+	for i := int(address); i < int(address)+int(length) && i < len(counts); i++ {
+		counts[i]++
+	}
+}
+
+// flags shows how an address was used as x (executed), r (read) and w
+// (written), with - for the uses that didn't happen.
+func (coverage *Coverage) flags(address int) string {
+	flags := []byte("---")
+
+	if coverage.Executed[address] > 0 {
+		flags[0] = 'x'
+	}
+	if coverage.Read[address] > 0 {
+		flags[1] = 'r'
+	}
+	if coverage.Written[address] > 0 {
+		flags[2] = 'w'
+	}
+
+	return string(flags)
+}
+
+// WriteListing writes an annotated listing of memory from start to end.
+// Executed addresses are disassembled with the number of times they ran,
+// everything else is listed as data, 8 bytes at most per line.
+func (coverage *Coverage) WriteListing(writer io.Writer, memory *[memorySize]byte, start, end int, symbols *Symbols) error {
+	if end > len(memory) {
+		end = len(memory)
+	}
+
+	executed, read, written := 0, 0, 0
+
+	for address := start; address < end; address++ {
+		if coverage.Executed[address] > 0 {
+			executed++
+		}
+		if coverage.Read[address] > 0 {
+			read++
+		}
+		if coverage.Written[address] > 0 {
+			written++
+		}
+	}
+
+	percent := func(count int) float64 {
+		if end <= start {
+			return 0
+		}
+
+		return float64(count) * 100 / float64(end-start)
+	}
+
+	fmt.Fprintf(writer, "; 0x%03X-0x%03X: %.1f%% executed, %.1f%% read, %.1f%% written\n",
+		start, end-1, percent(executed), percent(read), percent(written))
+	fmt.Fprintln(writer, "; x executed, r read by DRW or Fx65, w written by Fx33 or Fx55")
+
+	for address := start; address < end; {
+		if name, offset, ok := symbols.Label(uint16(address)); ok && offset == 0 {
+			fmt.Fprintf(writer, "%s:\n", name)
+		}
+
+		flags := coverage.flags(address)
+
+		if coverage.Executed[address] > 0 && address+1 < len(memory) {
+			op := uint16(memory[address])<<8 | uint16(memory[address+1])
+
+			fmt.Fprintf(writer, "0x%03X  %s  %-8d %04X  %s\n", address, flags, coverage.Executed[address], op, symbols.Disassemble(op))
+			address += 2
+			continue
+		}
+
+		data := []string{}
+		for len(data) < 8 && address < end && coverage.Executed[address] == 0 && coverage.flags(address) == flags {
+			data = append(data, fmt.Sprintf("%02X", memory[address]))
+			address++
+
+			if _, offset, ok := symbols.Label(uint16(address)); ok && offset == 0 {
+				break
+			}
+		}
+
+		fmt.Fprintf(writer, "0x%03X  %s  %-8s DB %s\n", address-len(data), flags, "", strings.Join(data, " "))
+	}
+
+	return nil
+}
+
+// Heatmap draws memory as a 64x64 grid of squares, one per address from left
+// to right and top to bottom. Executed addresses are green, data reads blue
+// and writes red, brighter the more they were used.
+func (coverage *Coverage) Heatmap(scale int) *image.RGBA {
+	const columns = 64
+
+	img := image.NewRGBA(image.Rect(0, 0, columns*scale, columns*scale))
+	max := uint32(1)
+
+	for address := range coverage.Executed {
+		for _, count := range []uint32{coverage.Executed[address], coverage.Read[address], coverage.Written[address]} {
+			if count > max {
+				max = count
+			}
+		}
+	}
+
+	// Used addresses are at least 0x60 bright, on a log scale up to 0xFF
+	brightness := func(count uint32) uint8 {
+		if count == 0 {
+			return 0
+		}
+
+		return uint8(0x60 + 0x9F*math.Log(float64(count))/math.Log(float64(max)+1))
+	}
+
+	for address := 0; address < columns*columns; address++ {
+		pixel := color.RGBA{0x10, 0x10, 0x10, 0xFF}
+
+		if address < len(coverage.Executed) {
+			r, g, b := brightness(coverage.Written[address]), brightness(coverage.Executed[address]), brightness(coverage.Read[address])
+
+			if r > 0 || g > 0 || b > 0 {
+				pixel = color.RGBA{r, g, b, 0xFF}
+			}
+		}
+
+		x := address % columns * scale
+		y := address / columns * scale
+
+		for dy := 0; dy < scale; dy++ {
+			for dx := 0; dx < scale; dx++ {
+				img.Set(x+dx, y+dy, pixel)
+			}
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Draws a 2 byte sprite, saves V0 and V1 after it and loops
+//
+//	0x200: LD I, 0x20A
+//	0x202: DRW V0, V0, 2
+//	0x204: LD [I], V1
+//	0x206: JP 0x206
+//	0x208: (unused)
+//	0x20A: sprite
+var coveredProgram = []byte{0xA2, 0x0A, 0xD0, 0x02, 0xF1, 0x55, 0x12, 0x06, 0x00, 0x00, 0xFF, 0x81, 0x00}
+
+func cover(t *testing.T) (*Coverage, *VM) {
+	vm := InitHeadlessVM()
+	vm.LoadProgram(coveredProgram)
+	coverage := &Coverage{}
+	vm.OnStep(func(pc, op uint16) {
+		coverage.Record(vm, pc, op)
+	})
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, vm.Step())
+	}
+
+	return coverage, vm
+}
+
+func TestCoverageRecord(t *testing.T) {
+	coverage, _ := cover(t)
+
+	assert.Equal(t, coverage.Executed[0x200], uint32(1))
+	assert.Equal(t, coverage.Executed[0x206], uint32(2))
+	assert.Equal(t, coverage.Executed[0x208], uint32(0))
+	assert.Equal(t, coverage.Read[0x20A:0x20D], []uint32{1, 1, 0})
+	assert.Equal(t, coverage.Written[0x20A:0x20D], []uint32{1, 1, 0})
+	assert.Equal(t, coverage.flags(0x20B), "-rw")
+}
+
+func TestCoverageListing(t *testing.T) {
+	coverage, vm := cover(t)
+	symbols, _ := ParseSymbols(strings.NewReader("0x20A sprite\n"))
+	listing := bytes.Buffer{}
+
+	assert.Nil(t, coverage.WriteListing(&listing, &vm.Memory, 0x200, 0x200+len(coveredProgram), symbols))
+	assert.Equal(t, listing.String(), ""+
+		"; 0x200-0x20C: 61.5% executed, 15.4% read, 15.4% written\n"+
+		"; x executed, r read by DRW or Fx65, w written by Fx33 or Fx55\n"+
+		"0x200  x--  1        A20A  LD I, sprite\n"+
+		"0x202  x--  1        D002  DRW V0, V0, 2\n"+
+		"0x204  x--  1        F155  LD [I], V1\n"+
+		"0x206  x--  2        1206  JP 0x206\n"+
+		"0x208  ---           DB 00 00\n"+
+		"sprite:\n"+
+		"0x20A  -rw           DB 00 00\n"+
+		"0x20C  ---           DB 00\n")
+}
+
+func TestCoverageHeatmap(t *testing.T) {
+	coverage, _ := cover(t)
+	img := coverage.Heatmap(2)
+
+	assert.Equal(t, img.Bounds().Dx(), 128)
+
+	executed := img.RGBAAt(0x200%64*2, 0x200/64*2)
+	assert.Equal(t, executed.R, uint8(0))
+	assert.True(t, executed.G >= 0x60)
+
+	data := img.RGBAAt(0x20A%64*2, 0x20A/64*2)
+	assert.True(t, data.R >= 0x60 && data.B >= 0x60)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Debugger pauses the VM when one of its breakpoint conditions holds after an
+// instruction. A nil Debugger never pauses.
+type Debugger struct {
+	Breakpoints []*Condition
+	Paused      bool
+	Reason      string    // Why the VM paused, empty when paused by hand
+	Symbols     *Symbols  // Names of addresses, nil when the ROM has no symbols
+	Trace       io.Writer // Receives every instruction before it runs, if set
+}
+
+// AddBreakpoint parses a condition and adds it to the breakpoints.
+func (debugger *Debugger) AddBreakpoint(source string) error {
+	condition, err := ParseCondition(source)
+
+	if err != nil {
+		return err
+	}
+
+	debugger.Breakpoints = append(debugger.Breakpoints, condition)
+
+	return nil
+}
+
+// Check evaluates every breakpoint after the VM ran the instruction op, and
+// pauses on the first one that holds.
+func (debugger *Debugger) Check(vm *VM, op uint16) bool {
+	if debugger == nil {
+		return false
+	}
+
+	var hit *Condition
+
+	for _, condition := range debugger.Breakpoints {
+		if condition.Check(vm, op) && hit == nil {
+			hit = condition
+		}
+	}
+
+	if hit != nil {
+		debugger.Break(hit.Source)
+	}
+
+	return hit != nil
+}
+
+// trace writes the instruction about to run to the trace.
+func (debugger *Debugger) trace(vm *VM, op uint16) {
+	if debugger == nil || debugger.Trace == nil {
+		return
+	}
+
+	fmt.Fprintf(debugger.Trace, "%-32s %04X  %s\n", debugger.Symbols.Describe(vm.PC), op, debugger.Symbols.Disassemble(op))
+}
+
+// symbols returns the symbols of the ROM, which may be nil.
+func (debugger *Debugger) symbols() *Symbols {
+	if debugger == nil {
+		return nil
+	}
+
+	return debugger.Symbols
+}
+
+func (debugger *Debugger) IsPaused() bool {
+	return debugger != nil && debugger.Paused
+}
+
+// Pause stops the VM until Resume is called.
+func (debugger *Debugger) Pause() {
+	debugger.Break("")
+}
+
+// Break pauses the VM for the given reason, shown in the status.
+func (debugger *Debugger) Break(reason string) {
+	debugger.Paused = true
+	debugger.Reason = reason
+}
+
+func (debugger *Debugger) Resume() {
+	debugger.Paused = false
+	debugger.Reason = ""
+}
+
+// Status describes why the VM is paused, or is empty while it runs.
+func (debugger *Debugger) Status() string {
+	switch {
+	case !debugger.IsPaused():
+		return ""
+	case debugger.Reason != "":
+		return "Break: " + debugger.Reason
+	}
+
+	return "Paused"
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebuggerBreakpoint(t *testing.T) {
+	// Count V0 up in an endless loop
+	//
+	//	0x200: ADD V0, 1
+	//	0x202: JP 0x200
+	vm := InitHeadlessVM()
+	vm.LoadProgram([]byte{0x70, 0x01, 0x12, 0x00})
+	vm.Debugger = &Debugger{}
+	assert.Nil(t, vm.Debugger.AddBreakpoint("V0 == 3 after ADD"))
+	assert.NotNil(t, vm.Debugger.AddBreakpoint("V0 =="))
+
+	for !vm.Debugger.IsPaused() {
+		assert.Nil(t, vm.DebugStep())
+	}
+
+	assert.Equal(t, vm.V[0], uint8(3))
+	assert.Equal(t, vm.PC, uint16(0x202))
+	assert.Equal(t, vm.Debugger.Status(), "Break: V0 == 3 after ADD")
+
+	vm.Debugger.Resume()
+	assert.Equal(t, vm.Debugger.Status(), "")
+
+	vm.Debugger.Pause()
+	assert.Equal(t, vm.Debugger.Status(), "Paused")
+}
+
+func TestNilDebugger(t *testing.T) {
+	var debugger *Debugger
+
+	assert.Equal(t, debugger.IsPaused(), false)
+	assert.Equal(t, debugger.Check(InitHeadlessVM(), 0x0000), false)
+	assert.Equal(t, debugger.Status(), "")
+}
+
+func TestDebuggerTrace(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.LoadProgram([]byte{0x22, 0x04, 0x00, 0x00, 0x60, 0x05})
+	trace := bytes.Buffer{}
+	symbols, _ := ParseSymbols(strings.NewReader("0x200 main\n0x204 init\n0x204 game.8o:7\n"))
+	vm.Debugger = &Debugger{Symbols: symbols, Trace: &trace}
+
+	assert.Nil(t, vm.DebugStep())
+	assert.Nil(t, vm.DebugStep())
+
+	assert.Equal(t, trace.String(), ""+
+		"0x200 main                       2204  CALL init\n"+
+		"0x204 init game.8o:7             6005  LD V0, 0x05\n")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisassemble(t *testing.T) {
+	instructions := map[uint16]string{
+		0x00E0: "CLS",
+		0x00EE: "RET",
+		0x0123: "SYS 0x123",
+		0x1228: "JP 0x228",
+		0x2ABC: "CALL 0xABC",
+		0x3A05: "SE VA, 0x05",
+		0x5120: "SE V1, V2",
+		0x5121: "DW 0x5121",
+		0x7F01: "ADD VF, 0x01",
+		0x8124: "ADD V1, V2",
+		0x812E: "SHL V1, V2",
+		0x8128: "DW 0x8128",
+		0xA234: "LD I, 0x234",
+		0xB300: "JP V0, 0x300",
+		0xD015: "DRW V0, V1, 5",
+		0xE3A1: "SKNP V3",
+		0xF40A: "LD V4, K",
+		0xF555: "LD [I], V5",
+		0xF565: "LD V5, [I]",
+		0xFFFF: "DW 0xFFFF",
+	}
+
+	for op, expected := range instructions {
+		assert.Equal(t, Disassemble(op), expected)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Display shows the frames of the screen to the user.
+type Display interface {
+	Init() error
+	// Render draws a frame of pixel intensities, as returned by Screen.Frame.
+	Render(frame *[width * height]byte)
+	Close()
+}
+
+// Input reads key presses from the user.
+type Input interface {
+	// PollEvent blocks until the user presses or releases a key.
+	PollEvent() Event
+	// ReportsReleases tells whether PollEvent returns key releases. When it
+	// doesn't, the keypad is reset periodically instead.
+	ReportsReleases() bool
+}
+
+// Speaker plays the buzzer, which sounds while the sound timer is non-zero.
+type Speaker interface {
+	Beep(on bool)
+}
+
+// Hotkey is an emulator command bound to a key outside of the keypad.
+type Hotkey int
+
+const (
+	NoHotkey Hotkey = iota
+	HotkeyScreenshot
+	HotkeyRecord
+	HotkeyPause // Pause or continue
+	HotkeyStep  // Run one instruction while paused
+	HotkeyReset // Start the program over, keeping memory
+	HotkeyHardReset
+	HotkeyFrame // Run one frame while paused
+	HotkeyFaster
+	HotkeySlower
+)
+
+// Button is a key outside of the keypad that a ROM can bind to a keypad
+// key, see VM.Buttons.
+type Button int
+
+const (
+	NoButton Button = iota
+	ButtonUp
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+	ButtonAction // Space
+)
+
+// Event is a key press or release read from an Input.
+type Event struct {
+	Key      byte   // Keypad key
+	Released bool   // The key was released rather than pressed
+	Hotkey   Hotkey // Set instead of Key for emulator commands
+	Button   Button // Set instead of Key for the arrow keys and space
+	Quit     bool   // The user asked to quit
+}
+
+// StateViewer is a display that can show the registers and memory of the VM
+// next to the screen, along with the status of the debugger.
+type StateViewer interface {
+	RenderState(state *State, debugger *Debugger)
+}
+
+// Frontend is a display together with the input read from the same device.
+type Frontend interface {
+	Display
+	Input
+	// Run runs the emulator loop. Frontends that need to own the main
+	// goroutine run it in the background.
+	Run(loop func() error) error
+}
+
+// Config holds the command line options frontends are built from.
+type Config struct {
+	Mode      RenderMode
+	Palette   Palette
+	Colors    string // Terminal colors: 16, 256 or truecolor
+	Scale     int    // Initial window scale
+	Scanlines bool
+	Panels    bool // Show the registers and memory next to the screen
+}
+
+// frontends are the frontends selectable with --frontend. Optional ones
+// register themselves from files behind build tags.
+var frontends = map[string]func(config Config) (Frontend, error){}
+
+// NewFrontend builds the frontend with the given name.
+func NewFrontend(name string, config Config) (Frontend, error) {
+	build, ok := frontends[name]
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown frontend: %s (available: %s)", name, strings.Join(frontendNames(), ", "))
+	}
+
+	return build(config)
+}
+
+func frontendNames() []string {
+	names := []string{}
+
+	for name := range frontends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Location is a register or a byte of memory read by the environment.
+type Location struct {
+	Register bool
+	Index    uint16 // Register number or memory address
+}
+
+// ParseLocation parses a register written as V0 to VF, or a memory address
+// such as 0x3F0.
+func ParseLocation(spec string) (Location, error) {
+	if len(spec) == 2 && (spec[0] == 'V' || spec[0] == 'v') {
+		index, err := strconv.ParseUint(spec[1:], 16, 4)
+
+		if err == nil {
+			return Location{Register: true, Index: uint16(index)}, nil
+		}
+	}
+
+	address, err := strconv.ParseUint(spec, 0, 16)
+
+	if err != nil || address >= uint64(len(State{}.Memory)) {
+		return Location{}, fmt.Errorf("Unknown location: %s", spec)
+	}
+
+	return Location{Index: uint16(address)}, nil
+}
+
+func (location Location) Read(vm *VM) uint8 {
+	if location.Register {
+		return vm.V[location.Index]
+	}
+
+	return vm.Memory[location.Index]
+}
+
+func (location Location) String() string {
+	if location.Register {
+		return fmt.Sprintf("V%X", location.Index)
+	}
+
+	return fmt.Sprintf("0x%03X", location.Index)
+}
+
+// RewardWatcher rewards the increase of the value at a location, such as the
+// score of a game, multiplied by Scale.
+type RewardWatcher struct {
+	Location Location
+	Scale    float64
+
+	last uint8
+}
+
+// ParseRewardWatcher parses a location with an optional scale, for example V6
+// or 0x3F0*-1 to penalize a value going up.
+func ParseRewardWatcher(spec string) (RewardWatcher, error) {
+	parts := strings.SplitN(spec, "*", 2)
+	location, err := ParseLocation(parts[0])
+
+	if err != nil {
+		return RewardWatcher{}, err
+	}
+
+	watcher := RewardWatcher{Location: location, Scale: 1}
+
+	if len(parts) == 2 {
+		if watcher.Scale, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return RewardWatcher{}, fmt.Errorf("Invalid reward scale: %s", parts[1])
+		}
+	}
+
+	return watcher, nil
+}
+
+// DoneCondition ends an episode once the value at a location equals Value,
+// for example when the lives counter reaches 0.
+type DoneCondition struct {
+	Location Location
+	Value    uint8
+}
+
+// ParseDoneCondition parses a condition written as location=value.
+func ParseDoneCondition(spec string) (DoneCondition, error) {
+	parts := strings.SplitN(spec, "=", 2)
+
+	if len(parts) != 2 {
+		return DoneCondition{}, fmt.Errorf("Invalid done condition: %s", spec)
+	}
+
+	location, err := ParseLocation(parts[0])
+
+	if err != nil {
+		return DoneCondition{}, err
+	}
+
+	value, err := strconv.ParseUint(parts[1], 0, 8)
+
+	if err != nil {
+		return DoneCondition{}, fmt.Errorf("Invalid done condition: %s", spec)
+	}
+
+	return DoneCondition{Location: location, Value: uint8(value)}, nil
+}
+
+// Observation is the screen after an action, one byte per pixel set to 0 or 1.
+type Observation [width * height]byte
+
+// NoAction runs a step without pressing any key.
+const NoAction = -1
+
+// Env wraps a headless VM in a reinforcement learning environment. Actions
+// are keypad keys, observations are the screen and rewards come from watching
+// memory or registers.
+type Env struct {
+	ROM       *ROM
+	Rewards   []RewardWatcher
+	Done      []DoneCondition
+	FrameSkip int // Frames run for each action
+	MaxSteps  int // Steps before an episode is cut short, 0 for no limit
+
+	vm    *VM
+	steps int
+	err   error
+}
+
+func NewEnv(rom *ROM) *Env {
+	return &Env{ROM: rom, FrameSkip: 4}
+}
+
+// Reset starts a new episode, with the VM set up the way the ROM plays best,
+// and returns the first observation. When the program can't be loaded, the
+// first step ends the episode, see Err.
+func (env *Env) Reset() Observation {
+	info, _ := LookupROM(env.ROM)
+	env.vm = InitHeadlessVM()
+	env.vm.Configure(info)
+	env.err = env.vm.LoadProgram(env.ROM.Program)
+	env.steps = 0
+
+	for i := range env.Rewards {
+		env.Rewards[i].last = env.Rewards[i].Location.Read(env.vm)
+	}
+
+	return env.observe()
+}
+
+// Step holds down the key of the action, or no key for NoAction, while
+// running FrameSkip frames. The episode is done when a done condition is met,
+// after MaxSteps steps or when the ROM fails, see Err.
+func (env *Env) Step(action int) (Observation, float64, bool) {
+	if env.vm == nil {
+		env.Reset()
+	}
+
+	env.vm.Keypad.Reset()
+
+	if action >= 0 && action < len(env.vm.Keypad.keys) {
+		env.vm.Keypad.PressKey(uint8(action))
+	}
+
+	cycles := env.FrameSkip * env.vm.TickRate / int(frameSpeed)
+
+	for i := 0; i < cycles && env.err == nil; i++ {
+		env.err = env.vm.Step()
+	}
+
+	env.steps++
+
+	reward := 0.0
+	for i := range env.Rewards {
+		watcher := &env.Rewards[i]
+		value := watcher.Location.Read(env.vm)
+		reward += float64(int(value)-int(watcher.last)) * watcher.Scale
+		watcher.last = value
+	}
+
+	return env.observe(), reward, env.done()
+}
+
+// Err returns the error that ended the episode, if the ROM failed to load or
+// run.
+func (env *Env) Err() error {
+	return env.err
+}
+
+func (env *Env) done() bool {
+	if env.err != nil || (env.MaxSteps > 0 && env.steps >= env.MaxSteps) {
+		return true
+	}
+
+	for _, condition := range env.Done {
+		if condition.Location.Read(env.vm) == condition.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (env *Env) observe() Observation {
+	return Observation(env.vm.Screen.Pixels)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLocation(t *testing.T) {
+	location, err := ParseLocation("V6")
+	assert.Nil(t, err)
+	assert.Equal(t, location, Location{Register: true, Index: 6})
+
+	location, err = ParseLocation("0x3F0")
+	assert.Nil(t, err)
+	assert.Equal(t, location, Location{Index: 0x3F0})
+	assert.Equal(t, location.String(), "0x3F0")
+
+	_, err = ParseLocation("0x1000")
+	assert.NotNil(t, err)
+}
+
+func TestParseRewardWatcher(t *testing.T) {
+	watcher, err := ParseRewardWatcher("vA*-0.5")
+	assert.Nil(t, err)
+	assert.Equal(t, watcher.Location, Location{Register: true, Index: 0xA})
+	assert.Equal(t, watcher.Scale, -0.5)
+
+	_, err = ParseRewardWatcher("V1*x")
+	assert.NotNil(t, err)
+}
+
+func TestParseDoneCondition(t *testing.T) {
+	condition, err := ParseDoneCondition("V7=0")
+	assert.Nil(t, err)
+	assert.Equal(t, condition, DoneCondition{Location: Location{Register: true, Index: 7}})
+
+	_, err = ParseDoneCondition("V7")
+	assert.NotNil(t, err)
+}
+
+func TestEnvStep(t *testing.T) {
+	// Add 1 to V6 while key 0 is held, in a loop of two or three instructions
+	// so that every frame ends at 0x200
+	//
+	//	0x200: SKNP V1
+	//	0x202: ADD V6, 1
+	//	0x204: JP 0x200
+	env := NewEnv(&ROM{Program: []byte{0xE1, 0xA1, 0x76, 0x01, 0x12, 0x00}})
+	env.FrameSkip = 3
+	env.Rewards = []RewardWatcher{{Location: Location{Register: true, Index: 6}, Scale: 0.5}}
+	env.Done = []DoneCondition{{Location: Location{Register: true, Index: 6}, Value: 4}}
+	env.Reset()
+
+	_, reward, done := env.Step(NoAction)
+	assert.Equal(t, reward, 0.0)
+	assert.Equal(t, done, false)
+
+	_, reward, done = env.Step(0)
+	assert.Equal(t, reward, 1.0)
+	assert.Equal(t, done, false)
+
+	_, reward, done = env.Step(0)
+	assert.Equal(t, reward, 1.0)
+	assert.Equal(t, done, true)
+
+	env.Reset()
+	assert.Equal(t, env.vm.V[6], uint8(0))
+}
+
+func TestEnvStepError(t *testing.T) {
+	env := NewEnv(&ROM{Program: []byte{0xFF, 0xFF}})
+	env.Reset()
+
+	_, _, done := env.Step(NoAction)
+	assert.Equal(t, done, true)
+	assert.NotNil(t, env.Err())
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition is a debugger condition in a small expression language, checked
+// after every instruction. Values are registers (V0 to VF, I, PC, SP, DT, ST),
+// memory (mem[address]) and numbers, combined with
+//
+//	& + -                 arithmetic
+//	== != < <= > >=       comparisons
+//	changes               true when a value differs from the last check
+//	! && || ( )           logic
+//
+// A trailing "after MNEMONIC" only lets the condition hold right after that
+// instruction ran, for example
+//
+//	PC == 0x2A4 && V3 > 10
+//	mem[I] changes
+//	VF == 1 after DRW
+type Condition struct {
+	Source string
+
+	eval  func(vm *VM) int
+	after string // Mnemonic of the instruction the condition follows
+}
+
+// ParseCondition compiles the source of a condition.
+func ParseCondition(source string) (*Condition, error) {
+	condition, err := parseCondition(source)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid condition %q: %s", source, err)
+	}
+
+	return condition, nil
+}
+
+func parseCondition(source string) (*Condition, error) {
+	tokens, err := tokenize(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &exprParser{tokens: tokens}
+	eval, err := parser.or()
+
+	if err != nil {
+		return nil, err
+	}
+
+	condition := &Condition{Source: source, eval: eval}
+
+	if parser.accept("after") {
+		mnemonic := parser.next()
+
+		if mnemonic == "" || !isIdentifier(mnemonic) {
+			return nil, errors.New("Expected an instruction after \"after\"")
+		}
+
+		condition.after = strings.ToUpper(mnemonic)
+	}
+
+	if token := parser.next(); token != "" {
+		return nil, fmt.Errorf("Unexpected %s", token)
+	}
+
+	return condition, nil
+}
+
+// Check evaluates the condition once the VM ran the instruction op.
+func (condition *Condition) Check(vm *VM, op uint16) bool {
+	// Evaluated even when the instruction doesn't match, so that "changes"
+	// compares with the value after the last instruction
+	holds := condition.eval(vm) != 0
+
+	if condition.after != "" && strings.Fields(Disassemble(op))[0] != condition.after {
+		return false
+	}
+
+	return holds
+}
+
+func tokenize(source string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case strings.ContainsRune("=!<>&|", r) && i+1 < len(runes) && isOperator(string(runes[i:i+2])):
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case strings.ContainsRune("+-&<>!()[]", r):
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			return nil, fmt.Errorf("Unexpected %c", r)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isOperator(token string) bool {
+	switch token {
+	case "==", "!=", "<=", ">=", "&&", "||":
+		return true
+	}
+
+	return false
+}
+
+func isIdentifier(token string) bool {
+	return unicode.IsLetter([]rune(token)[0])
+}
+
+// exprParser is a recursive descent parser turning tokens into closures.
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (parser *exprParser) peek() string {
+	if parser.pos < len(parser.tokens) {
+		return parser.tokens[parser.pos]
+	}
+
+	return ""
+}
+
+func (parser *exprParser) next() string {
+	token := parser.peek()
+	parser.pos++
+
+	return token
+}
+
+func (parser *exprParser) accept(token string) bool {
+	if strings.EqualFold(parser.peek(), token) {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func (parser *exprParser) or() (func(vm *VM) int, error) {
+	left, err := parser.and()
+
+	for err == nil && parser.accept("||") {
+		var right func(vm *VM) int
+
+		if right, err = parser.and(); err == nil {
+			l, r := left, right
+			// Both sides are evaluated to keep "changes" up to date
+			left = func(vm *VM) int {
+				a, b := l(vm), r(vm)
+				return boolInt(a != 0 || b != 0)
+			}
+		}
+	}
+
+	return left, err
+}
+
+func (parser *exprParser) and() (func(vm *VM) int, error) {
+	left, err := parser.not()
+
+	for err == nil && parser.accept("&&") {
+		var right func(vm *VM) int
+
+		if right, err = parser.not(); err == nil {
+			l, r := left, right
+			left = func(vm *VM) int {
+				a, b := l(vm), r(vm)
+				return boolInt(a != 0 && b != 0)
+			}
+		}
+	}
+
+	return left, err
+}
+
+func (parser *exprParser) not() (func(vm *VM) int, error) {
+	if !parser.accept("!") {
+		return parser.comparison()
+	}
+
+	operand, err := parser.not()
+
+	return func(vm *VM) int { return boolInt(operand(vm) == 0) }, err
+}
+
+var comparisons = map[string]func(a, b int) bool{
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+	"<":  func(a, b int) bool { return a < b },
+	"<=": func(a, b int) bool { return a <= b },
+	">":  func(a, b int) bool { return a > b },
+	">=": func(a, b int) bool { return a >= b },
+}
+
+func (parser *exprParser) comparison() (func(vm *VM) int, error) {
+	left, err := parser.sum()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.accept("changes") {
+		last, checked := 0, false
+
+		return func(vm *VM) int {
+			value := left(vm)
+			changed := checked && value != last
+			last, checked = value, true
+
+			return boolInt(changed)
+		}, nil
+	}
+
+	compare, ok := comparisons[parser.peek()]
+
+	if !ok {
+		return left, nil
+	}
+
+	parser.next()
+	right, err := parser.sum()
+
+	return func(vm *VM) int { return boolInt(compare(left(vm), right(vm))) }, err
+}
+
+func (parser *exprParser) sum() (func(vm *VM) int, error) {
+	left, err := parser.value()
+
+	for err == nil && (parser.peek() == "+" || parser.peek() == "-" || parser.peek() == "&") {
+		operator := parser.next()
+		var right func(vm *VM) int
+
+		if right, err = parser.value(); err == nil {
+			l, r := left, right
+
+			switch operator {
+			case "+":
+				left = func(vm *VM) int { return l(vm) + r(vm) }
+			case "-":
+				left = func(vm *VM) int { return l(vm) - r(vm) }
+			case "&":
+				left = func(vm *VM) int { return l(vm) & r(vm) }
+			}
+		}
+	}
+
+	return left, err
+}
+
+var exprRegisters = map[string]func(vm *VM) int{
+	"I":  func(vm *VM) int { return int(vm.I) },
+	"PC": func(vm *VM) int { return int(vm.PC) },
+	"SP": func(vm *VM) int { return int(vm.SP) },
+	"DT": func(vm *VM) int { return int(vm.DT) },
+	"ST": func(vm *VM) int { return int(vm.ST) },
+}
+
+func (parser *exprParser) value() (func(vm *VM) int, error) {
+	token := parser.next()
+	name := strings.ToUpper(token)
+
+	switch {
+	case token == "":
+		return nil, errors.New("Unexpected end")
+	case token == "(":
+		inner, err := parser.or()
+
+		if err == nil && parser.next() != ")" {
+			err = errors.New("Expected )")
+		}
+
+		return inner, err
+	case name == "MEM":
+		if parser.next() != "[" {
+			return nil, errors.New("Expected [ after mem")
+		}
+
+		address, err := parser.or()
+
+		if err == nil && parser.next() != "]" {
+			err = errors.New("Expected ]")
+		}
+
+		return func(vm *VM) int {
+			if i := address(vm); i >= 0 && i < len(vm.Memory) {
+				return int(vm.Memory[i])
+			}
+
+			return 0
+		}, err
+	case len(name) == 2 && name[0] == 'V' && strings.ContainsRune("0123456789ABCDEF", rune(name[1])):
+		x, _ := strconv.ParseUint(name[1:], 16, 4)
+
+		return func(vm *VM) int { return int(vm.V[x]) }, nil
+	case exprRegisters[name] != nil:
+		return exprRegisters[name], nil
+	}
+
+	number, err := strconv.ParseInt(token, 0, 32)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unexpected %s", token)
+	}
+
+	return func(vm *VM) int { return int(number) }, nil
+}
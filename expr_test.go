@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionCheck(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.PC = 0x2A4
+	vm.V[3] = 11
+	vm.I = 0x300
+	vm.Memory[0x301] = 0x80
+
+	conditions := map[string]bool{
+		"PC == 0x2A4 && V3 > 10":     true,
+		"pc == 0x2a4 && v3 > 11":     false,
+		"V3 >= 11 || V4 != 0":        true,
+		"!(V3 == 11)":                false,
+		"mem[I + 1] & 0x80":          true,
+		"mem[I - 1] == 0":            true,
+		"mem[0xFFFF] == 0":           true,
+		"V3 - 1 == 10 && SP == 0":    true,
+		"DT < 1 && ST <= 0 && I > 1": true,
+	}
+
+	for source, expected := range conditions {
+		condition, err := ParseCondition(source)
+		assert.Nil(t, err)
+		assert.Equal(t, condition.Check(vm, 0x0000), expected, source)
+	}
+}
+
+func TestConditionChanges(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.I = 0x300
+	condition, err := ParseCondition("mem[I] changes")
+	assert.Nil(t, err)
+
+	assert.Equal(t, condition.Check(vm, 0x0000), false)
+	assert.Equal(t, condition.Check(vm, 0x0000), false)
+
+	vm.Memory[0x300] = 1
+	assert.Equal(t, condition.Check(vm, 0x0000), true)
+	assert.Equal(t, condition.Check(vm, 0x0000), false)
+}
+
+func TestConditionAfter(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.V[0xF] = 1
+	condition, err := ParseCondition("VF == 1 after DRW")
+	assert.Nil(t, err)
+
+	assert.Equal(t, condition.Check(vm, 0xD015), true)
+	assert.Equal(t, condition.Check(vm, 0x6F01), false)
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for _, source := range []string{"", "PC ==", "V3 > 10)", "mem[I", "VG == 1", "V1 = 2", "V1 after", "V1 after 2"} {
+		_, err := ParseCondition(source)
+		assert.NotNil(t, err, source)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import "fmt"
+
+// Intensities of a pixel once a filter has been applied to the framebuffer.
+const (
+	pixelOff = 0x00
+	pixelOn  = 0xFF
+)
+
+// Filter post-processes the framebuffer before it is drawn. It turns the on/off
+// pixels into intensities between pixelOff and pixelOn, which hides the
+// flicker caused by games erasing and redrawing sprites with XOR.
+type Filter interface {
+	// Apply returns the intensity of every pixel for the next frame.
+	Apply(pixels *[width * height]byte) [width * height]byte
+	// Settled reports whether applying the filter again to unchanged pixels
+	// would produce the same frame.
+	Settled() bool
+}
+
+// NewFilter returns the filter registered under name.
+func NewFilter(name string) (Filter, error) {
+	switch name {
+	case "", "none":
+		return &NoFilter{}, nil
+	case "blend":
+		return &BlendFilter{}, nil
+	case "phosphor":
+		return &PhosphorFilter{Decay: 160}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown filter: %s", name)
+}
+
+// NoFilter draws the framebuffer as it is.
+type NoFilter struct{}
+
+func (filter *NoFilter) Apply(pixels *[width * height]byte) [width * height]byte {
+	frame := [width * height]byte{}
+
+	for i, pixel := range pixels {
+		if pixel != 0 {
+			frame[i] = pixelOn
+		}
+	}
+
+	return frame
+}
+
+func (filter *NoFilter) Settled() bool {
+	return true
+}
+
+// BlendFilter ORs the current frame with the previous one, so a sprite that is
+// erased and redrawn between two frames stays lit.
+type BlendFilter struct {
+	previous [width * height]byte
+	settled  bool
+}
+
+func (filter *BlendFilter) Apply(pixels *[width * height]byte) [width * height]byte {
+	frame := [width * height]byte{}
+
+	filter.settled = filter.previous == *pixels
+
+	for i, pixel := range pixels {
+		if pixel != 0 || filter.previous[i] != 0 {
+			frame[i] = pixelOn
+		}
+	}
+
+	filter.previous = *pixels
+
+	return frame
+}
+
+func (filter *BlendFilter) Settled() bool {
+	return filter.settled
+}
+
+// PhosphorFilter models the persistence of a CRT phosphor. Lit pixels are drawn
+// at full intensity and fade out over several frames once they are turned off.
+type PhosphorFilter struct {
+	// Decay is the fraction (out of 256) of the intensity a pixel keeps on
+	// every frame after it has been turned off.
+	Decay uint8
+
+	intensity [width * height]byte
+}
+
+func (filter *PhosphorFilter) Apply(pixels *[width * height]byte) [width * height]byte {
+	for i, pixel := range pixels {
+		if pixel != 0 {
+			filter.intensity[i] = pixelOn
+			continue
+		}
+
+		filter.intensity[i] = uint8(uint16(filter.intensity[i]) * uint16(filter.Decay) >> 8)
+	}
+
+	return filter.intensity
+}
+
+func (filter *PhosphorFilter) Settled() bool {
+	for _, intensity := range filter.intensity {
+		if intensity != pixelOff && intensity != pixelOn {
+			return false
+		}
+	}
+
+	return true
+}
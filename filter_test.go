@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFilter(t *testing.T) {
+	filter, err := NewFilter("none")
+	assert.Nil(t, err)
+	assert.Equal(t, filter, &NoFilter{})
+
+	filter, err = NewFilter("blend")
+	assert.Nil(t, err)
+	assert.Equal(t, filter, &BlendFilter{})
+
+	filter, err = NewFilter("phosphor")
+	assert.Nil(t, err)
+	assert.Equal(t, filter, &PhosphorFilter{Decay: 160})
+
+	_, err = NewFilter("sepia")
+	assert.EqualError(t, err, "Unknown filter: sepia")
+}
+
+func TestNoFilter(t *testing.T) {
+	filter := NoFilter{}
+	pixels := [width * height]byte{1, 0, 1}
+
+	frame := filter.Apply(&pixels)
+
+	assert.Equal(t, frame[0:3], []byte{pixelOn, pixelOff, pixelOn})
+	assert.True(t, filter.Settled())
+}
+
+func TestBlendFilter(t *testing.T) {
+	filter := BlendFilter{}
+	pixels := [width * height]byte{1, 0, 0}
+
+	frame := filter.Apply(&pixels)
+	assert.Equal(t, frame[0:3], []byte{pixelOn, pixelOff, pixelOff})
+	assert.False(t, filter.Settled())
+
+	// The sprite moved one pixel to the right
+	pixels = [width * height]byte{0, 1, 0}
+
+	frame = filter.Apply(&pixels)
+	assert.Equal(t, frame[0:3], []byte{pixelOn, pixelOn, pixelOff})
+	assert.False(t, filter.Settled())
+
+	frame = filter.Apply(&pixels)
+	assert.Equal(t, frame[0:3], []byte{pixelOff, pixelOn, pixelOff})
+	assert.True(t, filter.Settled())
+}
+
+func TestPhosphorFilter(t *testing.T) {
+	filter := PhosphorFilter{Decay: 128}
+	pixels := [width * height]byte{1, 1}
+
+	frame := filter.Apply(&pixels)
+	assert.Equal(t, frame[0:2], []byte{0xFF, 0xFF})
+	assert.True(t, filter.Settled())
+
+	pixels[1] = 0
+
+	frame = filter.Apply(&pixels)
+	assert.Equal(t, frame[0:2], []byte{0xFF, 0x7F})
+	assert.False(t, filter.Settled())
+
+	frame = filter.Apply(&pixels)
+	assert.Equal(t, frame[0:2], []byte{0xFF, 0x3F})
+
+	for i := 0; i < 6; i++ {
+		filter.Apply(&pixels)
+	}
+
+	assert.Equal(t, filter.intensity[0:2], []byte{0xFF, 0x00})
+	assert.True(t, filter.Settled())
+}
@@ -0,0 +1,614 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["gdb"] = gdb
+}
+
+// gdb runs the ROM headless and waits for debuggers speaking the GDB remote
+// serial protocol. Debuggers attach one after another to the same VM.
+func gdb(args []string) error {
+	flags := flag.NewFlagSet("gdb", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:1234", "Address to listen on")
+	path := flags.String("rom", "", "Path to the chip8 rom")
+	breakpoints := stringList{}
+	flags.Var(&breakpoints, "break", "Also stop when a condition holds, e.g. 'mem[I] changes'")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("Provide path to rom.\nExample: chip8 gdb --addr :1234 --rom ./breakout.ch8")
+	}
+
+	rom, err := readROMFile(*path)
+
+	if err != nil {
+		return err
+	}
+
+	vm := InitHeadlessVM()
+
+	if err := vm.LoadProgram(rom.Program); err != nil {
+		return err
+	}
+
+	stub := NewGDBStub(vm)
+
+	for _, source := range breakpoints {
+		if err := stub.Debugger.AddBreakpoint(source); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	logger.Println("Waiting for a debugger on", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			return err
+		}
+
+		logger.Println("Debugger attached from", conn.RemoteAddr())
+
+		if err := stub.Serve(conn); err != nil {
+			logger.Println(err)
+		}
+
+		conn.Close()
+		logger.Println("Debugger detached")
+	}
+}
+
+// Signals reported to the debugger when the VM stops.
+const (
+	gdbSigint  = 2  // Interrupted by the debugger
+	gdbSigill  = 4  // Unknown opcode
+	gdbSigtrap = 5  // Breakpoint or single step
+	gdbSigsegv = 11 // Stack overflow or underflow, or memory out of range
+)
+
+// Sent by the debugger outside of packets to stop a running VM.
+const gdbInterrupt = "\x03"
+
+// Registers in the order of the g packet, as described by gdbTargetXML:
+// V0 to VF, then I, PC, SP, DT and ST. Values are sent big-endian like the
+// rest of CHIP-8.
+const gdbRegisterCount = 21
+
+var gdbTargetXML = func() string {
+	xml := `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+<feature name="org.chip8.core">
+`
+	for i := 0; i < 16; i++ {
+		xml += fmt.Sprintf("<reg name=\"v%x\" bitsize=\"8\" type=\"uint8\"/>\n", i)
+	}
+
+	return xml + `<reg name="i" bitsize="16" type="data_ptr"/>
+<reg name="pc" bitsize="16" type="code_ptr"/>
+<reg name="sp" bitsize="8" type="uint8"/>
+<reg name="dt" bitsize="8" type="uint8"/>
+<reg name="st" bitsize="8" type="uint8"/>
+</feature>
+</target>
+`
+}()
+
+// GDBStub lets debuggers inspect and control a VM through a subset of the GDB
+// remote serial protocol: registers, memory, software breakpoints, step and
+// continue.
+type GDBStub struct {
+	Breakpoints map[uint16]bool
+	Debugger    *Debugger // Conditions that also stop the VM while it runs
+
+	vm    *VM
+	conn  io.ReadWriter
+	noAck bool
+}
+
+func NewGDBStub(vm *VM) *GDBStub {
+	return &GDBStub{vm: vm, Breakpoints: map[uint16]bool{}, Debugger: &Debugger{}}
+}
+
+// Serve answers the packets of a debugger until it detaches or the
+// connection is closed.
+func (stub *GDBStub) Serve(conn io.ReadWriter) error {
+	stub.conn = conn
+	stub.noAck = false
+
+	packets := make(chan string)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go readGDBPackets(bufio.NewReader(conn), packets, errs, done)
+
+	for {
+		var packet string
+
+		select {
+		case packet = <-packets:
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if packet == gdbInterrupt {
+			continue
+		}
+
+		if packet == "-" {
+			if _, err := io.WriteString(conn, "-"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !stub.noAck {
+			if _, err := io.WriteString(conn, "+"); err != nil {
+				return err
+			}
+		}
+
+		reply, detached, err := stub.handle(packet, packets, errs)
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := stub.send(reply); err != nil {
+			return err
+		}
+
+		if detached {
+			return nil
+		}
+	}
+}
+
+// readGDBPackets sends the payload of every valid packet, or the interrupt
+// byte on its own, until reading fails or done is closed. Packets with a bad
+// checksum are sent as "-" so that they are rejected.
+func readGDBPackets(reader *bufio.Reader, packets chan<- string, errs chan<- error, done <-chan struct{}) {
+	send := func(packet string) bool {
+		select {
+		case packets <- packet:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	for {
+		b, err := reader.ReadByte()
+
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		if b == gdbInterrupt[0] {
+			if !send(gdbInterrupt) {
+				return
+			}
+			continue
+		}
+
+		if b != '$' {
+			// Acks, and anything else between packets
+			continue
+		}
+
+		payload, err := reader.ReadString('#')
+
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		payload = strings.TrimSuffix(payload, "#")
+		checksum := make([]byte, 2)
+
+		if _, err := io.ReadFull(reader, checksum); err != nil {
+			errs <- err
+			return
+		}
+
+		if expected, err := strconv.ParseUint(string(checksum), 16, 8); err != nil || byte(expected) != gdbChecksum(payload) {
+			payload = "-"
+		}
+
+		if !send(payload) {
+			return
+		}
+	}
+}
+
+func gdbChecksum(payload string) byte {
+	sum := byte(0)
+
+	for i := 0; i < len(payload); i++ {
+		sum += payload[i]
+	}
+
+	return sum
+}
+
+func (stub *GDBStub) send(payload string) error {
+	_, err := fmt.Fprintf(stub.conn, "$%s#%02x", payload, gdbChecksum(payload))
+
+	return err
+}
+
+// handle returns the reply to a packet, and whether the debugger is done
+// with the session. It fails when the connection is lost while the VM runs.
+func (stub *GDBStub) handle(packet string, packets <-chan string, errs <-chan error) (string, bool, error) {
+	if packet == "" {
+		return "", false, nil
+	}
+
+	args := packet[1:]
+
+	switch packet[0] {
+	case '?':
+		return stopReply(gdbSigtrap), false, nil
+	case 'g':
+		return hex.EncodeToString(stub.registers()), false, nil
+	case 'G':
+		return stub.writeRegisters(args), false, nil
+	case 'p':
+		return stub.readRegister(args), false, nil
+	case 'P':
+		return stub.writeRegister(args), false, nil
+	case 'm':
+		return stub.readMemory(args), false, nil
+	case 'M':
+		return stub.writeMemory(args), false, nil
+	case 'Z', 'z':
+		return stub.breakpoint(packet[0] == 'Z', args), false, nil
+	case 's':
+		if err := stub.resumeAt(args); err != nil {
+			return "E01", false, nil
+		}
+		if _, err := stub.step(); err != nil {
+			return stopReply(errorSignal(err)), false, nil
+		}
+		return stopReply(gdbSigtrap), false, nil
+	case 'c':
+		if err := stub.resumeAt(args); err != nil {
+			return "E01", false, nil
+		}
+		signal, err := stub.run(packets, errs)
+		return stopReply(signal), false, err
+	case 'H':
+		return "OK", false, nil
+	case 'D':
+		return "OK", true, nil
+	case 'k':
+		return "", true, nil
+	case 'q', 'Q':
+		return stub.query(packet), false, nil
+	}
+
+	// Unsupported packets get an empty reply
+	return "", false, nil
+}
+
+func stopReply(signal int) string {
+	return fmt.Sprintf("S%02x", signal)
+}
+
+// resumeAt moves the PC to the address given to step or continue, if any.
+func (stub *GDBStub) resumeAt(args string) error {
+	if args == "" {
+		return nil
+	}
+
+	address, err := strconv.ParseUint(args, 16, 16)
+
+	if err != nil {
+		return err
+	}
+
+	if err := (State{PC: uint16(address), SP: stub.vm.SP, I: stub.vm.I}).Validate(); err != nil {
+		return err
+	}
+
+	stub.vm.PC = uint16(address)
+
+	return nil
+}
+
+// run steps the VM until it reaches a breakpoint, fails, or the debugger
+// interrupts it, and returns the signal to report. It fails when the
+// connection is lost.
+func (stub *GDBStub) run(packets <-chan string, errs <-chan error) (int, error) {
+	for {
+		select {
+		case packet := <-packets:
+			if packet == gdbInterrupt {
+				return gdbSigint, nil
+			}
+		case err := <-errs:
+			return 0, err
+		default:
+		}
+
+		op, err := stub.step()
+
+		if err != nil {
+			return errorSignal(err), nil
+		}
+
+		if stub.Breakpoints[stub.vm.PC] || stub.Debugger.Check(stub.vm, op) {
+			return gdbSigtrap, nil
+		}
+	}
+}
+
+// step runs an instruction and returns its opcode. A panic of the VM is
+// turned into an error, so that the debugger is told instead of the stub
+// going down.
+func (stub *GDBStub) step() (op uint16, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("VM panicked: %v", recovered)
+		}
+	}()
+
+	if err := stub.vm.checkPC(); err != nil {
+		return 0, err
+	}
+
+	op = stub.vm.decodeOpCode()
+
+	return op, stub.vm.Step()
+}
+
+// errorSignal returns the signal reporting an error of the VM.
+func errorSignal(err error) int {
+	if _, ok := err.(*UnknownOpCode); ok {
+		return gdbSigill
+	}
+
+	return gdbSigsegv
+}
+
+func (stub *GDBStub) query(packet string) string {
+	switch {
+	case strings.HasPrefix(packet, "qSupported"):
+		return "PacketSize=1000;qXfer:features:read+;QStartNoAckMode+"
+	case packet == "QStartNoAckMode":
+		stub.noAck = true
+		return "OK"
+	case packet == "qAttached":
+		return "1"
+	case packet == "qC":
+		return "QC1"
+	case packet == "qfThreadInfo":
+		return "m1"
+	case packet == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(packet, "qXfer:features:read:target.xml:"):
+		return xferRead(gdbTargetXML, strings.TrimPrefix(packet, "qXfer:features:read:target.xml:"))
+	}
+
+	return ""
+}
+
+// xferRead returns the part of a document asked for with offset,length.
+func xferRead(document, args string) string {
+	var offset, length int
+
+	if _, err := fmt.Sscanf(args, "%x,%x", &offset, &length); err != nil {
+		return "E01"
+	}
+
+	if offset >= len(document) {
+		return "l"
+	}
+
+	if offset+length >= len(document) {
+		return "l" + document[offset:]
+	}
+
+	return "m" + document[offset:offset+length]
+}
+
+// registers returns the values of all registers in the order of the g packet.
+func (stub *GDBStub) registers() []byte {
+	vm := stub.vm
+	values := append([]byte{}, vm.V[:]...)
+
+	return append(values, byte(vm.I>>8), byte(vm.I), byte(vm.PC>>8), byte(vm.PC), vm.SP, vm.DT, vm.ST)
+}
+
+// setRegisters sets all registers, unless PC, SP or I would be out of range.
+func (stub *GDBStub) setRegisters(values []byte) error {
+	vm := stub.vm
+	index := uint16(values[16])<<8 | uint16(values[17])
+	pc := uint16(values[18])<<8 | uint16(values[19])
+
+	if err := (State{PC: pc, SP: values[20], I: index}).Validate(); err != nil {
+		return err
+	}
+
+	copy(vm.V[:], values[0:16])
+	vm.I = index
+	vm.PC = pc
+	vm.SP = values[20]
+	vm.DT = values[21]
+	vm.ST = values[22]
+
+	return nil
+}
+
+// registerBytes returns the offset and size of a register in the g packet.
+func registerBytes(register int) (int, int) {
+	switch {
+	case register < 16:
+		return register, 1
+	case register == 16:
+		return 16, 2
+	case register == 17:
+		return 18, 2
+	}
+
+	return register + 2, 1
+}
+
+func (stub *GDBStub) writeRegisters(args string) string {
+	values, err := hex.DecodeString(args)
+
+	if err != nil || len(values) != len(stub.registers()) {
+		return "E01"
+	}
+
+	if err := stub.setRegisters(values); err != nil {
+		return "E02"
+	}
+
+	return "OK"
+}
+
+func (stub *GDBStub) readRegister(args string) string {
+	register, err := strconv.ParseUint(args, 16, 8)
+
+	if err != nil || register >= gdbRegisterCount {
+		return "E01"
+	}
+
+	offset, size := registerBytes(int(register))
+
+	return hex.EncodeToString(stub.registers()[offset : offset+size])
+}
+
+func (stub *GDBStub) writeRegister(args string) string {
+	parts := strings.SplitN(args, "=", 2)
+
+	if len(parts) != 2 {
+		return "E01"
+	}
+
+	register, err := strconv.ParseUint(parts[0], 16, 8)
+
+	if err != nil || register >= gdbRegisterCount {
+		return "E01"
+	}
+
+	value, err := hex.DecodeString(parts[1])
+	offset, size := registerBytes(int(register))
+
+	if err != nil || len(value) != size {
+		return "E01"
+	}
+
+	values := stub.registers()
+	copy(values[offset:], value)
+
+	if err := stub.setRegisters(values); err != nil {
+		return "E02"
+	}
+
+	return "OK"
+}
+
+// memoryRange parses addr,length and checks that it fits in memory.
+func (stub *GDBStub) memoryRange(args string) (int, int, error) {
+	var address, length int
+
+	if _, err := fmt.Sscanf(args, "%x,%x", &address, &length); err != nil {
+		return 0, 0, err
+	}
+
+	if address < 0 || length < 0 || address+length > len(stub.vm.Memory) {
+		return 0, 0, fmt.Errorf("Out of memory: %d bytes at 0x%X", length, address)
+	}
+
+	return address, length, nil
+}
+
+func (stub *GDBStub) readMemory(args string) string {
+	address, length, err := stub.memoryRange(args)
+
+	if err != nil {
+		return "E01"
+	}
+
+	return hex.EncodeToString(stub.vm.Memory[address : address+length])
+}
+
+func (stub *GDBStub) writeMemory(args string) string {
+	parts := strings.SplitN(args, ":", 2)
+
+	if len(parts) != 2 {
+		return "E01"
+	}
+
+	address, length, err := stub.memoryRange(parts[0])
+
+	if err != nil {
+		return "E01"
+	}
+
+	data, err := hex.DecodeString(parts[1])
+
+	if err != nil || len(data) != length {
+		return "E01"
+	}
+
+	copy(stub.vm.Memory[address:], data)
+
+	return "OK"
+}
+
+// breakpoint sets or removes a breakpoint from Z and z packets. Software and
+// hardware breakpoints are the same to the VM, watchpoints aren't supported.
+func (stub *GDBStub) breakpoint(set bool, args string) string {
+	var kind, address, size int
+
+	if _, err := fmt.Sscanf(args, "%d,%x,%x", &kind, &address, &size); err != nil {
+		return "E01"
+	}
+
+	if kind > 1 {
+		return ""
+	}
+
+	if set {
+		stub.Breakpoints[uint16(address)] = true
+	} else {
+		delete(stub.Breakpoints, uint16(address))
+	}
+
+	return "OK"
+}
@@ -0,0 +1,157 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gdbClient is a scripted debugger talking to a stub.
+type gdbClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (client *gdbClient) request(packet string) string {
+	fmt.Fprintf(client.conn, "$%s#%02x", packet, gdbChecksum(packet))
+
+	return client.reply()
+}
+
+// reply reads the next packet from the stub, skipping acks.
+func (client *gdbClient) reply() string {
+	client.reader.ReadString('$')
+	payload, _ := client.reader.ReadString('#')
+	io.ReadFull(client.reader, make([]byte, 2))
+
+	return strings.TrimSuffix(payload, "#")
+}
+
+func startGDBStub(program []byte) (*gdbClient, *VM, chan error) {
+	server, conn := net.Pipe()
+	vm := InitHeadlessVM()
+	vm.LoadProgram(program)
+	done := make(chan error)
+
+	go func() {
+		done <- NewGDBStub(vm).Serve(server)
+	}()
+
+	return &gdbClient{conn: conn, reader: bufio.NewReader(conn)}, vm, done
+}
+
+func TestGDBStubRegistersAndMemory(t *testing.T) {
+	client, vm, done := startGDBStub([]byte{0x60, 0x05, 0xA2, 0x34})
+
+	assert.Equal(t, client.request("?"), "S05")
+	assert.Equal(t, client.request("p11"), "0200")
+	assert.Equal(t, client.request("m200,4"), "6005a234")
+
+	assert.Equal(t, client.request("s"), "S05")
+	assert.Equal(t, client.request("s"), "S05")
+
+	registers := client.request("g")
+	assert.Equal(t, registers[0:2], "05")
+	assert.Equal(t, registers[32:40], "02340204")
+
+	assert.Equal(t, client.request("P3=2a"), "OK")
+	assert.Equal(t, vm.V[3], uint8(0x2A))
+	assert.Equal(t, client.request("M300,2:beef"), "OK")
+	assert.Equal(t, vm.Memory[0x300:0x302], []byte{0xBE, 0xEF})
+	assert.Equal(t, client.request("mfff,2"), "E01")
+
+	assert.Equal(t, client.request("D"), "OK")
+	assert.Nil(t, <-done)
+}
+
+func TestGDBStubBreakpoints(t *testing.T) {
+	// ADD V0, 1 in an endless loop, then an unknown opcode
+	//
+	//	0x200: ADD V0, 1
+	//	0x202: JP 0x200
+	//	0x204: 0xFFFF
+	client, vm, done := startGDBStub([]byte{0x70, 0x01, 0x12, 0x00, 0xFF, 0xFF})
+
+	assert.Equal(t, client.request("Z0,202,2"), "OK")
+	assert.Equal(t, client.request("c"), "S05")
+	assert.Equal(t, vm.PC, uint16(0x202))
+	assert.Equal(t, client.request("c"), "S05")
+	assert.Equal(t, vm.V[0], uint8(2))
+
+	assert.Equal(t, client.request("z0,202,2"), "OK")
+	assert.Equal(t, client.request("c204"), "S04")
+
+	// Interrupt the endless loop
+	assert.Equal(t, client.request("P11=0200"), "OK")
+	fmt.Fprintf(client.conn, "$c#63")
+	client.conn.Write([]byte(gdbInterrupt))
+	assert.Equal(t, client.reply(), "S02")
+
+	assert.Equal(t, client.request("k"), "")
+	assert.Nil(t, <-done)
+}
+
+func TestGDBStubTargetDescription(t *testing.T) {
+	client, _, done := startGDBStub(nil)
+
+	assert.Contains(t, client.request("qSupported:xmlRegisters=i386"), "qXfer:features:read+")
+
+	description := ""
+	for {
+		reply := client.request(fmt.Sprintf("qXfer:features:read:target.xml:%x,40", len(description)))
+		description += reply[1:]
+
+		if reply[0] == 'l' {
+			break
+		}
+	}
+
+	assert.Equal(t, description, gdbTargetXML)
+
+	client.conn.Close()
+	assert.Nil(t, <-done)
+}
+
+func TestGDBStubOutOfRange(t *testing.T) {
+	// CALL 0x200, calling itself until the stack overflows
+	client, vm, done := startGDBStub([]byte{0x22, 0x00})
+
+	assert.Equal(t, client.request("P11=0fff"), "E02")
+	assert.Equal(t, client.request("P12=10"), "E02")
+	assert.Equal(t, client.request("P10=1000"), "E02")
+	assert.Equal(t, client.request("sfff"), "E01")
+	assert.Equal(t, vm.PC, uint16(0x200))
+
+	assert.Equal(t, client.request("c"), "S0b")
+	assert.Equal(t, vm.SP, uint8(15))
+
+	assert.Equal(t, client.request("D"), "OK")
+	assert.Nil(t, <-done)
+}
+
+func TestGDBStubDetachWhileRunning(t *testing.T) {
+	// JP 0x200
+	client, _, done := startGDBStub([]byte{0x12, 0x00})
+
+	fmt.Fprintf(client.conn, "$c#%02x", gdbChecksum("c"))
+	ack, _ := client.reader.ReadByte()
+	assert.Equal(t, ack, byte('+'))
+
+	client.conn.Close()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The stub kept running after the debugger left")
+	}
+}
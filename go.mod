@@ -3,6 +3,7 @@ module chip8
 go 1.15
 
 require (
+	github.com/hajimehoshi/ebiten/v2 v2.0.8
 	github.com/nsf/termbox-go v0.0.0-20201124104050-ed494de23a00
 	github.com/stretchr/testify v1.6.1
 )
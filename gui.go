@@ -0,0 +1,219 @@
+//go:build gui
+// +build gui
+
+package main
+
+import (
+	"errors"
+	"image/color"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+func init() {
+	frontends["gui"] = NewWindow
+}
+
+var windowKeys = map[ebiten.Key]byte{
+	ebiten.Key1: 0x01, ebiten.Key2: 0x02, ebiten.Key3: 0x03, ebiten.Key4: 0x0C,
+	ebiten.KeyQ: 0x04, ebiten.KeyW: 0x05, ebiten.KeyE: 0x06, ebiten.KeyR: 0x0D,
+	ebiten.KeyA: 0x07, ebiten.KeyS: 0x08, ebiten.KeyD: 0x09, ebiten.KeyF: 0x0E,
+	ebiten.KeyZ: 0x0A, ebiten.KeyX: 0x00, ebiten.KeyC: 0x0B, ebiten.KeyV: 0x0F,
+}
+
+var windowButtons = map[ebiten.Key]Button{
+	ebiten.KeyUp:    ButtonUp,
+	ebiten.KeyDown:  ButtonDown,
+	ebiten.KeyLeft:  ButtonLeft,
+	ebiten.KeyRight: ButtonRight,
+	ebiten.KeySpace: ButtonAction,
+}
+
+var windowHotkeys = map[ebiten.Key]Hotkey{
+	ebiten.KeyF2:       HotkeyScreenshot,
+	ebiten.KeyF3:       HotkeyRecord,
+	ebiten.KeyF5:       HotkeyPause,
+	ebiten.KeyF6:       HotkeyStep,
+	ebiten.KeyF7:       HotkeyReset,
+	ebiten.KeyF8:       HotkeyHardReset,
+	ebiten.KeyF4:       HotkeyFrame,
+	ebiten.KeyPageUp:   HotkeyFaster,
+	ebiten.KeyPageDown: HotkeySlower,
+}
+
+var errWindowQuit = errors.New("Emulator stopped")
+
+// Window draws the screen in a resizable native window. The screen is scaled
+// by whole pixels to keep them square, and key releases are reported.
+type Window struct {
+	Palette   Palette
+	Scale     int
+	Scanlines bool
+
+	mutex   sync.Mutex
+	frame   [width * height]byte
+	image   *ebiten.Image
+	pressed map[ebiten.Key]bool
+	events  chan Event
+	stopped chan struct{}
+}
+
+func NewWindow(config Config) (Frontend, error) {
+	return &Window{
+		Palette:   config.Palette,
+		Scale:     config.Scale,
+		Scanlines: config.Scanlines,
+		pressed:   map[ebiten.Key]bool{},
+		events:    make(chan Event, 32),
+		stopped:   make(chan struct{}),
+	}, nil
+}
+
+func (window *Window) Init() error {
+	ebiten.SetWindowTitle("CHIP-8")
+	ebiten.SetWindowSize(width*window.Scale, height*window.Scale)
+	ebiten.SetWindowResizable(true)
+
+	return nil
+}
+
+func (window *Window) Close() {}
+
+// Run runs the emulator loop in the background, as the window has to own the
+// main goroutine. Closing the window stops the emulator.
+func (window *Window) Run(loop func() error) error {
+	result := make(chan error, 1)
+
+	go func() {
+		result <- loop()
+		close(window.stopped)
+	}()
+
+	if err := ebiten.RunGame(window); err != nil && err != errWindowQuit {
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (window *Window) Render(frame *[width * height]byte) {
+	window.mutex.Lock()
+	defer window.mutex.Unlock()
+
+	window.frame = *frame
+}
+
+func (window *Window) PollEvent() Event {
+	return <-window.events
+}
+
+func (window *Window) ReportsReleases() bool {
+	return true
+}
+
+// Update is called by ebiten 60 times per second to read the keyboard.
+func (window *Window) Update() error {
+	select {
+	case <-window.stopped:
+		return errWindowQuit
+	default:
+	}
+
+	for key, value := range windowKeys {
+		pressed := ebiten.IsKeyPressed(key)
+
+		if pressed != window.pressed[key] {
+			window.pressed[key] = pressed
+			window.send(Event{Key: value, Released: !pressed})
+		}
+	}
+
+	for key, button := range windowButtons {
+		pressed := ebiten.IsKeyPressed(key)
+
+		if pressed != window.pressed[key] {
+			window.pressed[key] = pressed
+			window.send(Event{Button: button, Released: !pressed})
+		}
+	}
+
+	for key, hotkey := range windowHotkeys {
+		if inpututil.IsKeyJustPressed(key) {
+			window.send(Event{Hotkey: hotkey})
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		window.send(Event{Quit: true})
+	}
+
+	return nil
+}
+
+// Draw is called by ebiten to draw the last rendered frame, centered in the
+// window at the largest whole scale that fits.
+func (window *Window) Draw(screen *ebiten.Image) {
+	window.mutex.Lock()
+	pixels := make([]byte, 0, width*height*4)
+
+	for _, intensity := range window.frame {
+		pixel := window.Palette.Shade(intensity)
+		pixels = append(pixels, pixel.R, pixel.G, pixel.B, 0xFF)
+	}
+	window.mutex.Unlock()
+
+	if window.image == nil {
+		window.image = ebiten.NewImage(width, height)
+	}
+
+	window.image.ReplacePixels(pixels)
+
+	screenWidth, screenHeight := screen.Size()
+	scale := screenWidth / width
+
+	if screenHeight/height < scale {
+		scale = screenHeight / height
+	}
+
+	if scale < 1 {
+		scale = 1
+	}
+
+	left := float64(screenWidth-width*scale) / 2
+	top := float64(screenHeight-height*scale) / 2
+
+	options := &ebiten.DrawImageOptions{}
+	options.GeoM.Scale(float64(scale), float64(scale))
+	options.GeoM.Translate(left, top)
+	screen.DrawImage(window.image, options)
+
+	// Darken the bottom line of every row of pixels
+	if window.Scanlines && scale >= 3 {
+		for y := 1; y <= height; y++ {
+			line := top + float64(y*scale-1)
+			ebitenutil.DrawRect(screen, left, line, float64(width*scale), 1, color.RGBA{A: 0x80})
+		}
+	}
+}
+
+// Layout uses the size of the window as is, so Draw can scale by whole pixels.
+func (window *Window) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+// send queues an event without blocking ebiten's loop, dropping it if the
+// emulator is not keeping up.
+func (window *Window) send(event Event) {
+	select {
+	case window.events <- event:
+	default:
+	}
+}
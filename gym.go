@@ -0,0 +1,127 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+)
+
+func init() {
+	commands["gym"] = gym
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// gym runs the ROM as a reinforcement learning environment driven by JSON
+// lines on stdin, answering each with a JSON line on stdout.
+func gym(args []string) error {
+	flags := flag.NewFlagSet("gym", flag.ExitOnError)
+	path := flags.String("rom", "", "Path to the chip8 rom")
+	frameSkip := flags.Int("frame-skip", 4, "Frames run for each action")
+	maxSteps := flags.Int("max-steps", 0, "Steps before an episode ends, 0 for no limit")
+	rewards := stringList{}
+	flags.Var(&rewards, "reward", "Register or address whose increase is rewarded, e.g. V6 or 0x3F0*-1")
+	done := stringList{}
+	flags.Var(&done, "done", "End the episode when a register or address has a value, e.g. V7=0")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("Provide path to rom.\nExample: chip8 gym --rom ./breakout.ch8 --reward V6")
+	}
+
+	rom, err := readROMFile(*path)
+
+	if err != nil {
+		return err
+	}
+
+	env := NewEnv(rom)
+	env.FrameSkip = *frameSkip
+	env.MaxSteps = *maxSteps
+
+	for _, spec := range rewards {
+		watcher, err := ParseRewardWatcher(spec)
+
+		if err != nil {
+			return err
+		}
+
+		env.Rewards = append(env.Rewards, watcher)
+	}
+
+	for _, spec := range done {
+		condition, err := ParseDoneCondition(spec)
+
+		if err != nil {
+			return err
+		}
+
+		env.Done = append(env.Done, condition)
+	}
+
+	return runGym(env, os.Stdin, os.Stdout)
+}
+
+// gymRequest is a line read by the bridge, either {"Reset": true} or
+// {"Action": 5}. An action of -1 presses no key.
+type gymRequest struct {
+	Reset  bool
+	Action *int
+}
+
+type gymResponse struct {
+	Observation *Observation `json:",omitempty"`
+	Reward      float64
+	Done        bool
+	Error       string `json:",omitempty"`
+}
+
+// runGym answers requests until the input is closed.
+func runGym(env *Env, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	encoder := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		request := gymRequest{}
+		response := gymResponse{}
+
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			response.Error = err.Error()
+		} else if request.Reset {
+			observation := env.Reset()
+			response.Observation = &observation
+		} else if request.Action != nil && *request.Action >= NoAction && *request.Action < 16 {
+			observation, reward, done := env.Step(*request.Action)
+			response = gymResponse{Observation: &observation, Reward: reward, Done: done}
+
+			if err := env.Err(); err != nil {
+				response.Error = err.Error()
+			}
+		} else {
+			response.Error = "Expected Reset or an Action between -1 and 15"
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
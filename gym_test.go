@@ -0,0 +1,34 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunGym(t *testing.T) {
+	// Draw the font sprite of 0 and loop forever
+	env := NewEnv(&ROM{Program: []byte{0xD0, 0x05, 0x12, 0x02}})
+	in := strings.NewReader("{\"Reset\": true}\n{\"Action\": 3}\n{\"Action\": 16}\n")
+	out := bytes.Buffer{}
+
+	assert.Nil(t, runGym(env, in, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 3)
+
+	response := gymResponse{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &response))
+	assert.Equal(t, response.Observation[0:5], []byte{1, 1, 1, 1, 0})
+	assert.Equal(t, response.Done, false)
+
+	response = gymResponse{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[2]), &response))
+	assert.Equal(t, response.Error, "Expected Reset or an Action between -1 and 15")
+}
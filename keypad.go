@@ -1,7 +1,5 @@
 package main
 
-import "github.com/nsf/termbox-go"
-
 type Keypad struct {
 	keys [16]bool
 }
@@ -14,27 +12,34 @@ func (keypad *Keypad) PressKey(key uint8) {
 	keypad.keys[key] = true
 }
 
+func (keypad *Keypad) ReleaseKey(key uint8) {
+	keypad.keys[key] = false
+}
+
 func (keypad *Keypad) CheckPressed(key uint8) bool {
 	return keypad.keys[key]
 }
 
+// Pressed returns the lowest key that is currently pressed.
+func (keypad *Keypad) Pressed() (uint8, bool) {
+	for key, pressed := range keypad.keys {
+		if pressed {
+			return uint8(key), true
+		}
+	}
+
+	return 0, false
+}
+
+// Layout of the keypad on a QWERTY keyboard, shared by the frontends.
+//
+//	1 2 3 C      1 2 3 4
+//	4 5 6 D  ->  Q W E R
+//	7 8 9 E      A S D F
+//	A 0 B F      Z X C V
 var keyMap = map[rune]byte{
 	'1': 0x01, '2': 0x02, '3': 0x03, '4': 0x0C,
 	'q': 0x04, 'w': 0x05, 'e': 0x06, 'r': 0x0D,
 	'a': 0x07, 's': 0x08, 'd': 0x09, 'f': 0x0E,
 	'z': 0x0A, 'x': 0x00, 'c': 0x0B, 'v': 0x0F,
 }
-
-var pollEvent = func() byte {
-	event := termbox.PollEvent()
-	return keyMap[event.Ch]
-}
-
-var fetchKey = func() byte {
-	for {
-		event := termbox.PollEvent()
-		if event.Type == termbox.EventKey {
-			return keyMap[event.Ch]
-		}
-	}
-}
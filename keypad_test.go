@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeypad(t *testing.T) {
+	keypad := Keypad{}
+
+	_, pressed := keypad.Pressed()
+	assert.False(t, pressed)
+
+	keypad.PressKey(0x0C)
+	keypad.PressKey(0x03)
+
+	key, pressed := keypad.Pressed()
+	assert.True(t, pressed)
+	assert.Equal(t, key, uint8(0x03))
+
+	keypad.ReleaseKey(0x03)
+
+	assert.False(t, keypad.CheckPressed(0x03))
+	assert.True(t, keypad.CheckPressed(0x0C))
+
+	keypad.Reset()
+
+	_, pressed = keypad.Pressed()
+	assert.False(t, pressed)
+}
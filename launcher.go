@@ -0,0 +1,443 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	previewFPS   = 30
+	previewSteps = 20 // Instructions run per preview frame, 5 times the normal speed
+)
+
+// Extensions of the files listed in the library.
+var libraryExtensions = map[string]bool{".ch8": true, ".sc8": true, ".xo8": true, ".zip": true, ".gz": true}
+
+// LibraryEntry is a ROM of the library.
+type LibraryEntry struct {
+	Path  string
+	ROM   *ROM // Nil when the ROM can't be loaded
+	Info  ROMInfo
+	Known bool  // The ROM is in the database
+	Err   error // Why the ROM can't be loaded
+}
+
+// ScanLibrary loads the ROMs in dir, sorted by title.
+func ScanLibrary(dir string) ([]*LibraryEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*LibraryEntry{}
+
+	for _, file := range files {
+		if file.IsDir() || !libraryExtensions[strings.ToLower(filepath.Ext(file.Name()))] {
+			continue
+		}
+
+		entry := &LibraryEntry{Path: filepath.Join(dir, file.Name())}
+		entry.ROM, entry.Err = readROMFile(entry.Path)
+
+		if entry.Err == nil {
+			entry.Info, entry.Known = LookupROM(entry.ROM)
+		}
+
+		if !entry.Known {
+			entry.Info.Title = file.Name()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Info.Title) < strings.ToLower(entries[j].Info.Title)
+	})
+
+	return entries, nil
+}
+
+// Matches reports whether every word of the query is found in the title,
+// author, platform or file name of the ROM.
+func (entry *LibraryEntry) Matches(query string) bool {
+	text := strings.ToLower(strings.Join([]string{
+		entry.Info.Title, entry.Info.Author, entry.Info.Platform.Name, filepath.Base(entry.Path),
+	}, " "))
+
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Launcher is a menu of the ROMs of the library, searched by typing, with a
+// live preview of the selected one.
+type Launcher struct {
+	Entries []*LibraryEntry
+	Status  string // Shown at the bottom until the next key, like why the last ROM stopped
+
+	display  *displayFlags
+	terminal *Terminal // Reads keys and converts the colors of the preview
+	query    string
+	selected int // Index in the matching entries
+
+	preview      *VM
+	previewEntry *LibraryEntry
+	previewErr   error
+	palette      Palette
+}
+
+func NewLauncher(entries []*LibraryEntry, display *displayFlags) (*Launcher, error) {
+	output, err := ParseColorMode(display.colors)
+
+	if err != nil {
+		return nil, err
+	}
+
+	terminal := &Terminal{Mode: ModeHalf, Output: output}
+
+	return &Launcher{Entries: entries, display: display, terminal: terminal}, nil
+}
+
+// Choose shows the menu until the user picks a ROM, whose path is returned,
+// or quits, which returns an empty path.
+func (launcher *Launcher) Choose() (string, error) {
+	if err := launcher.terminal.Init(); err != nil {
+		return "", err
+	}
+
+	events := make(chan termbox.Event)
+	done := make(chan struct{})
+
+	defer launcher.terminal.Close()
+	defer close(done)
+
+	go func() {
+		for {
+			event, ok := launcher.terminal.poll()
+			if !ok {
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second / previewFPS)
+	defer ticker.Stop()
+
+	for {
+		launcher.draw()
+
+		select {
+		case event := <-events:
+			if path, chosen := launcher.handle(event); chosen {
+				return path, nil
+			}
+		case <-ticker.C:
+			launcher.stepPreview()
+		}
+	}
+}
+
+// matching returns the entries matching the search query.
+func (launcher *Launcher) matching() []*LibraryEntry {
+	entries := []*LibraryEntry{}
+
+	for _, entry := range launcher.Entries {
+		if entry.Matches(launcher.query) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// current returns the selected entry, nil when nothing matches the query.
+func (launcher *Launcher) current() *LibraryEntry {
+	entries := launcher.matching()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if launcher.selected >= len(entries) {
+		launcher.selected = len(entries) - 1
+	}
+
+	return entries[launcher.selected]
+}
+
+// handle reacts to a key, returning the path of the ROM to run once one is
+// chosen, or an empty path when the user quits.
+func (launcher *Launcher) handle(event termbox.Event) (string, bool) {
+	if event.Type != termbox.EventKey {
+		return "", false
+	}
+
+	launcher.Status = ""
+	count := len(launcher.matching())
+
+	switch event.Key {
+	case termbox.KeyEsc, termbox.KeyCtrlC:
+		return "", true
+	case termbox.KeyEnter:
+		entry := launcher.current()
+
+		if entry == nil {
+			break
+		}
+
+		if entry.Err != nil {
+			launcher.Status = entry.Err.Error()
+			break
+		}
+
+		return entry.Path, true
+	case termbox.KeyArrowUp:
+		launcher.selected--
+	case termbox.KeyArrowDown:
+		launcher.selected++
+	case termbox.KeyPgup:
+		launcher.selected -= 10
+	case termbox.KeyPgdn:
+		launcher.selected += 10
+	case termbox.KeyHome:
+		launcher.selected = 0
+	case termbox.KeyEnd:
+		launcher.selected = count - 1
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if query := []rune(launcher.query); len(query) > 0 {
+			launcher.query = string(query[:len(query)-1])
+		}
+	case termbox.KeySpace:
+		launcher.query += " "
+	default:
+		if event.Ch != 0 {
+			launcher.query += string(event.Ch)
+			launcher.selected = 0
+		}
+	}
+
+	if launcher.selected >= count {
+		launcher.selected = count - 1
+	}
+
+	if launcher.selected < 0 {
+		launcher.selected = 0
+	}
+
+	return "", false
+}
+
+// stepPreview runs the selected ROM for a frame, starting it over when the
+// selection changed. A ROM that crashes the VM stops its preview rather than
+// the launcher.
+func (launcher *Launcher) stepPreview() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			launcher.previewErr = fmt.Errorf("The VM panicked: %v", recovered)
+		}
+	}()
+
+	entry := launcher.current()
+
+	if entry != launcher.previewEntry {
+		launcher.previewEntry = entry
+		launcher.preview = nil
+		launcher.previewErr = nil
+
+		if entry == nil || entry.Err != nil {
+			return
+		}
+
+		palette, err := PaletteForROM(entry.Path, launcher.display.theme, entry.Info.Colors)
+
+		if err != nil {
+			palette = Themes[defaultTheme]
+		}
+
+		launcher.palette = palette
+		launcher.preview = InitHeadlessVM()
+		launcher.preview.Configure(entry.Info)
+		launcher.previewErr = launcher.preview.LoadProgram(entry.ROM.Program)
+	}
+
+	if launcher.preview == nil || launcher.previewErr != nil {
+		return
+	}
+
+	for i := 0; i < previewSteps; i++ {
+		if launcher.previewErr = launcher.preview.Step(); launcher.previewErr != nil {
+			return
+		}
+	}
+}
+
+// details describes the selected ROM under the preview.
+func (launcher *Launcher) details(entry *LibraryEntry) []string {
+	lines := []string{entry.Info.String(), ""}
+
+	if entry.Err != nil {
+		return append(lines, "Can't be loaded:", entry.Err.Error())
+	}
+
+	lines = append(lines,
+		"Platform  "+entry.Info.Platform.Name,
+		fmt.Sprintf("File      %s, %d bytes", filepath.Base(entry.Path), len(entry.ROM.Program)),
+		"Quirks    "+describeQuirks(entry.Info.Quirks),
+	)
+
+	if len(entry.Info.Keys) > 0 {
+		lines = append(lines, "Keys      "+describeButtons(entry.Info.Keys))
+	}
+
+	if !entry.Known {
+		lines = append(lines, "", "Not in the ROM database")
+	}
+
+	if launcher.previewErr != nil {
+		lines = append(lines, "", "Preview stopped: "+launcher.previewErr.Error())
+	}
+
+	return lines
+}
+
+// describeQuirks lists the quirks that are set.
+func describeQuirks(quirks Quirks) string {
+	names := []string{}
+
+	if quirks.ShiftVy {
+		names = append(names, "shift Vy")
+	}
+	if quirks.IncrementI {
+		names = append(names, "increment I")
+	}
+	if quirks.JumpVx {
+		names = append(names, "jump Vx")
+	}
+	if quirks.LogicResetsVF {
+		names = append(names, "logic resets VF")
+	}
+
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ", ")
+}
+
+var buttonNames = []struct {
+	button Button
+	name   string
+}{
+	{ButtonUp, "Up"},
+	{ButtonDown, "Down"},
+	{ButtonLeft, "Left"},
+	{ButtonRight, "Right"},
+	{ButtonAction, "Space"},
+}
+
+// describeButtons lists the keypad keys pressed by the buttons.
+func describeButtons(keys map[Button]byte) string {
+	bindings := []string{}
+
+	for _, button := range buttonNames {
+		if key, ok := keys[button.button]; ok {
+			bindings = append(bindings, fmt.Sprintf("%s %X", button.name, key))
+		}
+	}
+
+	return strings.Join(bindings, ", ")
+}
+
+// draw draws the search query, the list of matching ROMs on the left and the
+// preview of the selected one on the right.
+func (launcher *Launcher) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+
+	columns, rows := termbox.Size()
+	previewWidth, previewHeight := ModeHalf.Size()
+	listWidth := columns - previewWidth - panelGap
+	entries := launcher.matching()
+
+	drawText(0, 0, listWidth, fmt.Sprintf("%d of %d ROMs", len(entries), len(launcher.Entries)), termbox.AttrBold)
+	drawText(0, 1, listWidth, "Search: "+launcher.query+"_", termbox.ColorDefault)
+
+	listHeight := rows - 4
+	top := 0
+
+	if launcher.selected >= listHeight {
+		top = launcher.selected - listHeight + 1
+	}
+
+	for i := top; i < len(entries) && i-top < listHeight; i++ {
+		attribute := termbox.ColorDefault
+		if i == launcher.selected {
+			attribute = termbox.AttrReverse
+		}
+
+		drawText(0, 3+i-top, listWidth, fmt.Sprintf(" %-*s", listWidth, entries[i].Info.String()), attribute)
+	}
+
+	status := launcher.Status
+	if status == "" {
+		status = "Up/Down select, Enter plays, type to search, Esc quits"
+	}
+
+	drawText(0, rows-1, columns, status, termbox.ColorDefault)
+
+	entry := launcher.current()
+	left := listWidth + panelGap
+
+	if entry == nil {
+		termbox.Flush()
+		return
+	}
+
+	if launcher.preview != nil && launcher.previewEntry == entry {
+		frame := launcher.preview.Screen.Frame()
+		gradient := launcher.terminal.Output != termbox.OutputNormal
+
+		for row := 0; row < previewHeight; row++ {
+			for column := 0; column < previewWidth; column++ {
+				cell := ModeHalf.Cell(frame, launcher.palette, column, row, gradient)
+				termbox.SetCell(left+column, 1+row, cell.Ch, launcher.terminal.attribute(cell.Fg), launcher.terminal.attribute(cell.Bg))
+			}
+		}
+	}
+
+	for i, line := range launcher.details(entry) {
+		drawText(left, previewHeight+2+i, previewWidth, line, termbox.ColorDefault)
+	}
+
+	termbox.Flush()
+}
+
+// drawText writes text at x, y, cut to width cells.
+func drawText(x, y, width int, text string, attribute termbox.Attribute) {
+	for i, ch := range []rune(text) {
+		if i >= width {
+			break
+		}
+
+		termbox.SetCell(x+i, y, ch, attribute, termbox.ColorDefault)
+	}
+}
@@ -0,0 +1,140 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	breakout, err := ioutil.ReadFile("roms/breakout.ch8")
+	assert.Nil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "zz.ch8"), breakout, 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "alpha.sc8"), []byte{0x00, 0xE0}, 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "empty.ch8"), []byte{}, 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("Notes\n"), 0644))
+
+	entries, err := ScanLibrary(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+
+	assert.Equal(t, entries[0].Info.String(), "alpha.sc8")
+	assert.Equal(t, entries[0].Info.Platform, PlatformSCHIP)
+	assert.Equal(t, entries[1].Info.String(), "Breakout by Carmelo Cortez")
+	assert.Equal(t, entries[1].Known, true)
+	assert.Equal(t, entries[2].Info.String(), "empty.ch8")
+	assert.NotNil(t, entries[2].Err)
+
+	_, err = ScanLibrary(filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+}
+
+func TestLibraryEntryMatches(t *testing.T) {
+	entry := &LibraryEntry{
+		Path: "roms/breakout.ch8",
+		Info: ROMInfo{Title: "Breakout", Author: "Carmelo Cortez", Platform: PlatformCHIP8},
+	}
+
+	assert.Equal(t, entry.Matches(""), true)
+	assert.Equal(t, entry.Matches("break"), true)
+	assert.Equal(t, entry.Matches("CORTEZ chip-8"), true)
+	assert.Equal(t, entry.Matches("breakout.ch8"), true)
+	assert.Equal(t, entry.Matches("break pong"), false)
+}
+
+func TestLauncherHandle(t *testing.T) {
+	entries, err := ScanLibrary("roms")
+	assert.Nil(t, err)
+
+	launcher, err := NewLauncher(entries, &displayFlags{colors: "16"})
+	assert.Nil(t, err)
+
+	key := func(key termbox.Key, ch rune) (string, bool) {
+		return launcher.handle(termbox.Event{Type: termbox.EventKey, Key: key, Ch: ch})
+	}
+
+	key(termbox.KeyArrowDown, 0)
+	key(termbox.KeyArrowDown, 0)
+	assert.Equal(t, launcher.current().Info.Title, "Keypad Test")
+
+	key(0, 'l')
+	key(0, 'u')
+	key(0, 'n')
+	assert.Equal(t, launcher.current().Info.Title, "Lunar Lander")
+
+	key(termbox.KeyBackspace2, 0)
+	key(termbox.KeyBackspace2, 0)
+	key(termbox.KeyBackspace2, 0)
+	key(termbox.KeyEnd, 0)
+	assert.Equal(t, launcher.current().Info.Title, "Random Number Test")
+
+	path, chosen := key(termbox.KeyEnter, 0)
+	assert.Equal(t, chosen, true)
+	assert.Equal(t, path, filepath.Join("roms", "random_num_demo.ch8"))
+
+	path, chosen = key(termbox.KeyEsc, 0)
+	assert.Equal(t, chosen, true)
+	assert.Equal(t, path, "")
+
+	// Nothing matches
+	key(0, 'x')
+	key(0, 'y')
+	assert.Nil(t, launcher.current())
+
+	_, chosen = key(termbox.KeyEnter, 0)
+	assert.Equal(t, chosen, false)
+}
+
+func TestLauncherPreview(t *testing.T) {
+	entries, err := ScanLibrary("roms")
+	assert.Nil(t, err)
+
+	launcher, err := NewLauncher(entries, &displayFlags{colors: "16"})
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		launcher.stepPreview()
+	}
+
+	assert.Equal(t, launcher.previewEntry.Info.Title, "Breakout")
+	assert.Equal(t, launcher.preview.Quirks, QuirksVIP)
+	assert.NotEqual(t, launcher.preview.PC, uint16(0x200))
+
+	// Selecting another ROM starts it over
+	launcher.handle(termbox.Event{Type: termbox.EventKey, Key: termbox.KeyArrowDown})
+	launcher.stepPreview()
+
+	assert.Equal(t, launcher.previewEntry.Info.Title, "Chip-8 Picture")
+	assert.Equal(t, launcher.details(launcher.previewEntry)[2], "Platform  CHIP-8")
+}
+
+func TestLauncherPreviewPanic(t *testing.T) {
+	entries, err := ScanLibrary("roms")
+	assert.Nil(t, err)
+
+	launcher, err := NewLauncher(entries, &displayFlags{colors: "16"})
+	assert.Nil(t, err)
+
+	launcher.stepPreview()
+	launcher.preview.OnStep(func(pc, op uint16) {
+		panic("broken")
+	})
+
+	assert.NotPanics(t, launcher.stepPreview)
+	assert.EqualError(t, launcher.previewErr, "The VM panicked: broken")
+
+	details := launcher.details(launcher.previewEntry)
+	assert.Equal(t, details[len(details)-1], "Preview stopped: The VM panicked: broken")
+}
@@ -1,58 +1,483 @@
+//go:build !js
+// +build !js
+
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 )
 
+// commands are run with "chip8 <command> [options]". Without a command the
+// ROM given with --rom is run in the terminal.
+var commands = map[string]func(args []string) error{}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	path := flag.String("rom", "", "Path to the chip8 rom")
+	library := flag.String("library", "roms", "Directory of the ROMs to pick from when --rom isn't given")
+	session := addSessionFlags(flag.CommandLine)
 	flag.Parse()
 
-	if *path == "" {
+	if *path != "" {
+		if err := session.run(*path); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	entries, err := ScanLibrary(*library)
+
+	if err != nil || len(entries) == 0 {
 		fmt.Println("Provide path to rom.\nExample: chip8 --rom ./breakout.ch8")
 		os.Exit(1)
 	}
 
-	rom, err := os.Open(*path)
+	launcher, err := NewLauncher(entries, session.display)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer rom.Close()
+	for {
+		path, err := launcher.Choose()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if path == "" {
+			return
+		}
+
+		if err := session.run(path); err != nil {
+			launcher.Status = err.Error()
+		}
+	}
+}
+
+// sessionFlags are the options of a session running a ROM.
+type sessionFlags struct {
+	display     *displayFlags
+	scale       int
+	frontend    string
+	scanlines   bool
+	breakpoints stringList
+	symbols     string
+	trace       string
+	profile     string
+	stacks      string
+	coverage    string
+	breakOnSMC  bool
+	panels      bool
+	captureDir  string
+	record      string
+	watch       bool
+	watchSource string
+	build       string
+	keepState   bool
+	speed       string
+}
+
+func addSessionFlags(flags *flag.FlagSet) *sessionFlags {
+	session := &sessionFlags{display: addDisplayFlags(flags)}
+
+	flags.IntVar(&session.scale, "scale", 8, "Size of a pixel in screenshots, recordings and the window")
+	flags.StringVar(&session.frontend, "frontend", "terminal", "Frontend: "+strings.Join(frontendNames(), ", "))
+	flags.BoolVar(&session.scanlines, "scanlines", false, "Draw CRT scanlines in the window")
+	flags.Var(&session.breakpoints, "break", "Pause when a condition holds, e.g. 'PC == 0x2A4 && V3 > 10' (F5 continues, F6 steps)")
+	flags.StringVar(&session.symbols, "symbols", "", "Symbol file naming the addresses of the ROM, by default the .sym file next to it")
+	flags.StringVar(&session.trace, "trace", "", "Write every instruction run to this file")
+	flags.StringVar(&session.profile, "profile", "", "Write a report of the hot spots, opcodes and subroutines to this file on exit")
+	flags.StringVar(&session.stacks, "profile-stacks", "", "Write the call stacks in the folded flame graph format to this file on exit")
+	flags.StringVar(&session.coverage, "coverage", "", "Write which addresses were executed, read and written to this file on exit, as a heatmap if it ends in .png")
+	flags.BoolVar(&session.breakOnSMC, "break-on-smc", false, "Pause when the ROM overwrites code that ran or runs code it wrote")
+	flags.BoolVar(&session.panels, "panels", false, "Show the registers and memory next to the screen in the terminal")
+	flags.StringVar(&session.captureDir, "capture-dir", ".", "Directory for screenshots (F2) and recordings (F3)")
+	flags.StringVar(&session.record, "record", "", "Record the whole session as an animated GIF to this path")
+	flags.BoolVar(&session.watch, "watch", false, "Reload the ROM when it changes on disk")
+	flags.StringVar(&session.watchSource, "watch-source", "", "Run --build when this source file changes, then reload the ROM")
+	flags.StringVar(&session.build, "build", "", "Shell command that assembles the ROM from --watch-source")
+	flags.BoolVar(&session.keepState, "keep-state", false, "Keep the registers, timers and screen when the ROM is reloaded")
+	flags.StringVar(&session.speed, "speed", "1", "Speed as a multiple of normal, like 0.5 or 2, or unlimited (Page Up and Page Down change it)")
+
+	return session
+}
 
-	romInfo, err := rom.Stat()
+// run runs the ROM at path until the user quits.
+func (session *sessionFlags) run(path string) error {
+	rom, err := readROMFile(path)
 
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	filter, err := NewFilter(session.display.filter)
+
+	if err != nil {
+		return err
+	}
+
+	info, known := LookupROM(rom)
+	config, err := session.display.config(path, info.Colors)
+
+	if err != nil {
+		return err
 	}
 
-	program := ReadROM(rom, int(romInfo.Size()))
+	config.Scale = session.scale
+	config.Scanlines = session.scanlines
+	config.Panels = session.panels
+	palette := config.Palette
 
-	screen := Screen{}
-	screen.Init()
-	defer screen.Close()
+	screen := Screen{Filter: filter}
 
 	vm := InitVM()
+	defer vm.Close()
 
 	vm.SetScreen(&screen)
-	vm.LoadProgram(program)
+	vm.Configure(info)
+
+	speed, err := ParseSpeed(session.speed)
+
+	if err != nil {
+		return err
+	}
+
+	vm.SetSpeed(speed)
+
+	if err := vm.LoadProgram(rom.Program); err != nil {
+		return err
+	}
+
+	if session.watch || session.watchSource != "" {
+		reloader, err := NewReloader(path, session.watchSource, session.build, session.keepState)
+
+		if err != nil {
+			return err
+		}
+
+		vm.OnFrame(func() {
+			reloader.Frame(vm)
+		})
+	}
+
+	debugger := &Debugger{}
+
+	if debugger.Symbols, err = SymbolsForROM(path, session.symbols); err != nil {
+		return err
+	}
+
+	if debugger.Symbols == nil {
+		debugger.Symbols = rom.Symbols
+	}
+
+	var trace *bufio.Writer
+
+	if session.trace != "" {
+		traceFile, err := os.Create(session.trace)
+
+		if err != nil {
+			return err
+		}
+
+		defer traceFile.Close()
+
+		trace = bufio.NewWriter(traceFile)
+		debugger.Trace = trace
+	}
+
+	for _, source := range session.breakpoints {
+		if err := debugger.AddBreakpoint(source); err != nil {
+			return err
+		}
+	}
+
+	frontend, err := NewFrontend(session.frontend, config)
+
+	if err != nil {
+		return err
+	}
+
+	viewer, canViewState := frontend.(StateViewer)
+
+	if session.panels && !canViewState {
+		return fmt.Errorf("The %s frontend can't show panels", session.frontend)
+	}
 
 	logFile, err := os.OpenFile("chip8.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+
 	if err != nil {
-		os.Exit(1)
+		return err
+	}
+
+	defer logFile.Close()
+
+	if err := frontend.Init(); err != nil {
+		return err
+	}
+	defer frontend.Close()
+
+	vm.Display = frontend
+	vm.Input = frontend
+
+	if frontend.ReportsReleases() {
+		vm.ResetKeysClock = nil
+	}
+	vm.Debugger = debugger
+
+	var profiler *Profiler
+
+	if session.profile != "" || session.stacks != "" {
+		profiler = NewProfiler()
+		vm.OnStep(profiler.Step)
+	}
+
+	smc := &SelfModification{Symbols: debugger.Symbols, OnWarning: func(warning string) {
+		vm.Logger.Println("Self-modifying code:", warning)
+
+		if session.breakOnSMC {
+			debugger.Break(warning)
+		}
+	}}
+
+	vm.OnStep(func(pc, op uint16) {
+		smc.Record(vm, pc, op)
+	})
+
+	coverage := &Coverage{}
+
+	if session.coverage != "" {
+		vm.OnStep(func(pc, op uint16) {
+			coverage.Record(vm, pc, op)
+		})
 	}
 
 	vm.Logger.SetOutput(logFile)
 
-	go vm.EventListener()
+	if known {
+		vm.Logger.Printf("Running %s, a %s ROM", info, info.Platform.Name)
+	}
+
+	if info.Platform != PlatformCHIP8 {
+		vm.Logger.Printf("%s is a %s ROM, only its CHIP-8 instructions are supported", info.Title, info.Platform.Name)
+	}
+
+	var recorder *Recorder
+
+	if session.record != "" {
+		recorder = NewRecorder(session.record, palette, session.scale)
+	}
+
+	saveRecording := func() {
+		if err := recorder.Save(); err != nil {
+			vm.Logger.Println(err)
+			return
+		}
+
+		vm.Logger.Println("Saved recording to", recorder.Path)
+	}
+
+	vm.OnHotkey(HotkeyScreenshot, func() {
+		screenshot := CapturePath(session.captureDir, path, "png")
+
+		if err := SavePNG(screenshot, screen.Image(palette, session.scale)); err != nil {
+			vm.Logger.Println(err)
+			return
+		}
+
+		vm.Logger.Println("Saved screenshot to", screenshot)
+	})
+
+	vm.OnHotkey(HotkeyRecord, func() {
+		if recorder == nil {
+			recorder = NewRecorder(CapturePath(session.captureDir, path, "gif"), palette, session.scale)
+			return
+		}
+
+		saveRecording()
+		recorder = nil
+	})
+
+	vm.OnHotkey(HotkeyPause, func() {
+		if vm.Debugger.Paused {
+			vm.Debugger.Resume()
+		} else {
+			vm.Debugger.Pause()
+		}
+	})
+
+	vm.OnHotkey(HotkeyStep, func() {
+		if !vm.Debugger.Paused {
+			vm.Debugger.Pause()
+			return
+		}
+
+		if err := vm.DebugStep(); err != nil {
+			vm.Logger.Println(err)
+		}
+	})
+
+	vm.OnHotkey(HotkeyFrame, func() {
+		if !vm.Debugger.Paused {
+			vm.Debugger.Pause()
+			return
+		}
+
+		if err := vm.AdvanceFrame(); err != nil {
+			vm.Logger.Println(err)
+		}
+	})
+
+	changeSpeed := func(steps int) {
+		vm.SetSpeed(NextSpeed(vm.Speed, steps))
+		vm.Logger.Println("Speed:", FormatSpeed(vm.Speed))
+	}
+
+	vm.OnHotkey(HotkeyFaster, func() {
+		changeSpeed(1)
+	})
+
+	vm.OnHotkey(HotkeySlower, func() {
+		changeSpeed(-1)
+	})
+
+	vm.OnHotkey(HotkeyReset, func() {
+		vm.Reset(false)
+		vm.Logger.Println("Soft reset")
+	})
+
+	vm.OnHotkey(HotkeyHardReset, func() {
+		vm.Reset(true)
+		vm.Logger.Println("Hard reset")
+	})
+
+	vm.OnFrame(func() {
+		if recorder != nil {
+			recorder.Frame(&screen)
+		}
+	})
+
+	if session.panels {
+		vm.OnFrame(func() {
+			state := vm.SaveState()
+			viewer.RenderState(&state, vm.Debugger)
+		})
+	}
+
+	err = frontend.Run(func() error {
+		go vm.EventListener()
+
+		return vm.Start()
+	})
 
-	err = vm.Start()
+	if recorder != nil {
+		saveRecording()
+	}
+
+	if trace != nil {
+		trace.Flush()
+	}
+
+	if session.profile != "" {
+		err := writeFile(session.profile, func(writer io.Writer) error {
+			return profiler.WriteReport(writer, &vm.Memory, debugger.Symbols)
+		})
+
+		if err != nil {
+			vm.Logger.Println(err)
+		}
+	}
+
+	if strings.HasSuffix(session.coverage, ".png") {
+		if err := SavePNG(session.coverage, coverage.Heatmap(session.scale)); err != nil {
+			vm.Logger.Println(err)
+		}
+	} else if session.coverage != "" {
+		err := writeFile(session.coverage, func(writer io.Writer) error {
+			return coverage.WriteListing(writer, &vm.Memory, 0x200, 0x200+len(rom.Program), debugger.Symbols)
+		})
+
+		if err != nil {
+			vm.Logger.Println(err)
+		}
+	}
+
+	if session.stacks != "" {
+		err := writeFile(session.stacks, func(writer io.Writer) error {
+			return profiler.WriteFolded(writer, debugger.Symbols)
+		})
+
+		if err != nil {
+			vm.Logger.Println(err)
+		}
+	}
+
+	return err
+}
+
+// writeFile creates the file at path and writes it with write.
+func writeFile(path string, write func(writer io.Writer) error) error {
+	file, err := os.Create(path)
 
 	if err != nil {
-		os.Exit(1)
+		return err
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
 	}
+
+	return file.Close()
+}
+
+// displayFlags are the options shared by every command that draws the screen.
+type displayFlags struct {
+	filter string
+	mode   string
+	theme  string
+	colors string
+}
+
+func addDisplayFlags(flags *flag.FlagSet) *displayFlags {
+	display := &displayFlags{}
+
+	flags.StringVar(&display.filter, "filter", "none", "Anti-flicker filter: none, blend or phosphor")
+	flags.StringVar(&display.mode, "mode", "auto", "Render mode: auto, full, block, half or braille")
+	flags.StringVar(&display.theme, "theme", "", "Color theme (green, amber, white, lcd, inverted) or colors like #000000,#FFB000")
+	flags.StringVar(&display.colors, "colors", "16", "Terminal colors: 16, 256 or truecolor")
+
+	return display
+}
+
+// config returns the frontend configuration for the ROM at romPath, with the
+// colors recommended for it unless others were asked for.
+func (display *displayFlags) config(romPath, colors string) (Config, error) {
+	mode, err := ParseRenderMode(display.mode)
+
+	if err != nil {
+		return Config{}, err
+	}
+
+	palette, err := PaletteForROM(romPath, display.theme, colors)
+
+	if err != nil {
+		return Config{}, err
+	}
+
+	return Config{Mode: mode, Palette: palette, Colors: display.colors}, nil
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"syscall/js"
+)
+
+// main runs the ROM given by the "rom" query parameter of the page on the
+// canvas with the id "screen". The "theme" and "filter" parameters work like
+// the command line options.
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	location, err := url.Parse(js.Global().Get("location").Get("href").String())
+
+	if err != nil {
+		return err
+	}
+
+	query := location.Query()
+
+	response, err := http.Get(query.Get("rom"))
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Could not load %s: %s", query.Get("rom"), response.Status)
+	}
+
+	rom, err := ReadROM(response.Body, path.Base(response.Request.URL.Path))
+
+	if err != nil {
+		return err
+	}
+
+	filter, err := NewFilter(query.Get("filter"))
+
+	if err != nil {
+		return err
+	}
+
+	theme := query.Get("theme")
+
+	if theme == "" {
+		theme = defaultTheme
+	}
+
+	palette, err := ParsePalette(theme)
+
+	if err != nil {
+		return err
+	}
+
+	canvas := NewCanvas(js.Global().Get("document").Call("getElementById", "screen"), palette)
+
+	if err := canvas.Init(); err != nil {
+		return err
+	}
+
+	screen := Screen{Filter: filter}
+
+	vm := InitVM()
+
+	vm.SetScreen(&screen)
+	vm.Display = canvas
+	vm.Input = canvas
+	vm.Speaker = canvas
+	vm.ResetKeysClock = nil
+	vm.Logger.SetOutput(os.Stderr)
+
+	if err := vm.LoadProgram(rom.Program); err != nil {
+		return err
+	}
+
+	go vm.EventListener()
+
+	return vm.Start()
+}
@@ -0,0 +1,176 @@
+package main
+
+import "fmt"
+
+// RenderMode selects how pixels are packed into terminal cells.
+type RenderMode int
+
+const (
+	// ModeAuto picks the largest mode with square pixels that fits the terminal.
+	ModeAuto RenderMode = iota
+	// ModeFull draws every pixel as one cell, which stretches the image 2:1.
+	ModeFull
+	// ModeBlock draws every pixel as two cells side by side.
+	ModeBlock
+	// ModeHalf packs two vertical pixels in one cell using ▀.
+	ModeHalf
+	// ModeBraille packs 2x4 pixels in one cell using Braille patterns.
+	ModeBraille
+)
+
+var renderModes = map[string]RenderMode{
+	"auto":    ModeAuto,
+	"full":    ModeFull,
+	"block":   ModeBlock,
+	"half":    ModeHalf,
+	"braille": ModeBraille,
+}
+
+// ParseRenderMode returns the render mode with the given name.
+func ParseRenderMode(name string) (RenderMode, error) {
+	mode, ok := renderModes[name]
+
+	if !ok {
+		return ModeAuto, fmt.Errorf("Unknown render mode: %s", name)
+	}
+
+	return mode, nil
+}
+
+// CellSize returns how many pixels wide and tall a single cell is in this mode.
+func (mode RenderMode) CellSize() (int, int) {
+	switch mode {
+	case ModeHalf:
+		return 1, 2
+	case ModeBraille:
+		return 2, 4
+	}
+
+	return 1, 1
+}
+
+// Size returns the number of terminal columns and rows the screen takes up.
+func (mode RenderMode) Size() (int, int) {
+	if mode == ModeBlock {
+		return width * 2, height
+	}
+
+	cellWidth, cellHeight := mode.CellSize()
+
+	return width / cellWidth, height / cellHeight
+}
+
+// autoMode returns the largest mode with square pixels that fits in a terminal
+// of the given size, falling back to Braille on very small terminals.
+func autoMode(columns, rows int) RenderMode {
+	for _, mode := range []RenderMode{ModeBlock, ModeHalf} {
+		modeColumns, modeRows := mode.Size()
+
+		if modeColumns <= columns && modeRows <= rows {
+			return mode
+		}
+	}
+
+	return ModeBraille
+}
+
+// Cell is a character cell of a text terminal showing part of the screen.
+type Cell struct {
+	Ch     rune
+	Fg, Bg Color
+}
+
+// Cell returns the cell at the given column and row of the frame. Terminals
+// limited to 16 colors can't draw gradients, so without gradient intensities
+// are drawn with shade characters, or as either on or off.
+func (mode RenderMode) Cell(frame *[width * height]byte, palette Palette, column, row int, gradient bool) Cell {
+	cellWidth, cellHeight := mode.CellSize()
+	x := column * cellWidth
+	y := row * cellHeight
+
+	if mode == ModeBlock {
+		x = column / 2
+	}
+
+	color := func(intensity byte) Color {
+		if gradient {
+			return palette.Shade(intensity)
+		}
+
+		if intensity < 0x80 {
+			return palette.Plane(0)
+		}
+
+		return palette.Plane(1)
+	}
+
+	switch mode {
+	case ModeHalf:
+		return Cell{Ch: '▀', Fg: color(frame[y*width+x]), Bg: color(frame[(y+1)*width+x])}
+	case ModeBraille:
+		return Cell{Ch: braille(frame, x, y), Fg: palette.Plane(1), Bg: palette.Plane(0)}
+	}
+
+	intensity := frame[y*width+x]
+
+	if gradient {
+		return Cell{Ch: '█', Fg: palette.Shade(intensity), Bg: palette.Plane(0)}
+	}
+
+	return Cell{Ch: shade(intensity), Fg: palette.Plane(1), Bg: palette.Plane(0)}
+}
+
+// rowsChanged reports whether the intensities of the given pixel rows differ
+// between two frames.
+func rowsChanged(before, after *[width * height]byte, y, rows int) bool {
+	start := y * width
+	end := (y + rows) * width
+
+	for i := start; i < end; i++ {
+		if before[i] != after[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shade returns the block character used to draw a pixel of the given intensity.
+func shade(intensity byte) rune {
+	switch {
+	case intensity == pixelOff:
+		return ' '
+	case intensity < 0x40:
+		return '░'
+	case intensity < 0x80:
+		return '▒'
+	case intensity < 0xC0:
+		return '▓'
+	}
+
+	return '█'
+}
+
+// Bit of the Braille pattern for each pixel of a 2x4 cell, indexed by [y][x].
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// braille returns the Braille pattern for the 2x4 pixels whose top left corner
+// is at x, y.
+func braille(frame *[width * height]byte, x, y int) rune {
+	pattern := rune(0x2800)
+
+	for dy, dots := range brailleDots {
+		for dx, dot := range dots {
+			if frame[(y+dy)*width+x+dx] >= 0x80 {
+				pattern |= dot
+			}
+		}
+	}
+
+	return pattern
+}
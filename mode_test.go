@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRenderMode(t *testing.T) {
+	mode, err := ParseRenderMode("braille")
+	assert.Nil(t, err)
+	assert.Equal(t, mode, ModeBraille)
+
+	_, err = ParseRenderMode("sixel")
+	assert.EqualError(t, err, "Unknown render mode: sixel")
+}
+
+func TestRenderModeSize(t *testing.T) {
+	columns, rows := ModeFull.Size()
+	assert.Equal(t, []int{columns, rows}, []int{64, 32})
+
+	columns, rows = ModeBlock.Size()
+	assert.Equal(t, []int{columns, rows}, []int{128, 32})
+
+	columns, rows = ModeHalf.Size()
+	assert.Equal(t, []int{columns, rows}, []int{64, 16})
+
+	columns, rows = ModeBraille.Size()
+	assert.Equal(t, []int{columns, rows}, []int{32, 8})
+}
+
+func TestAutoMode(t *testing.T) {
+	assert.Equal(t, autoMode(200, 50), ModeBlock)
+	assert.Equal(t, autoMode(80, 24), ModeHalf)
+	assert.Equal(t, autoMode(40, 10), ModeBraille)
+	assert.Equal(t, autoMode(10, 5), ModeBraille)
+}
+
+func TestRenderModeCell(t *testing.T) {
+	palette := Themes["white"]
+	black := Color{0x00, 0x00, 0x00}
+	white := Color{0xFF, 0xFF, 0xFF}
+	gray := Color{0x80, 0x80, 0x80}
+
+	frame := [width * height]byte{}
+	frame[1] = 0x80
+	frame[width+1] = pixelOn
+
+	assert.Equal(t, ModeFull.Cell(&frame, palette, 1, 0, false), Cell{Ch: '▓', Fg: white, Bg: black})
+	assert.Equal(t, ModeFull.Cell(&frame, palette, 1, 0, true), Cell{Ch: '█', Fg: gray, Bg: black})
+	assert.Equal(t, ModeBlock.Cell(&frame, palette, 3, 0, false), Cell{Ch: '▓', Fg: white, Bg: black})
+	assert.Equal(t, ModeBlock.Cell(&frame, palette, 4, 0, false), Cell{Ch: ' ', Fg: white, Bg: black})
+	assert.Equal(t, ModeHalf.Cell(&frame, palette, 1, 0, false), Cell{Ch: '▀', Fg: white, Bg: white})
+	assert.Equal(t, ModeHalf.Cell(&frame, palette, 1, 0, true), Cell{Ch: '▀', Fg: gray, Bg: white})
+	assert.Equal(t, ModeBraille.Cell(&frame, palette, 0, 0, false), Cell{Ch: '⠘', Fg: white, Bg: black})
+}
+
+func TestRowsChanged(t *testing.T) {
+	before := [width * height]byte{}
+	after := [width * height]byte{}
+
+	assert.False(t, rowsChanged(&before, &after, 0, 2))
+
+	after[3*width+5] = pixelOn
+
+	assert.False(t, rowsChanged(&before, &after, 0, 2))
+	assert.True(t, rowsChanged(&before, &after, 2, 2))
+	assert.True(t, rowsChanged(&before, &after, 3, 1))
+}
+
+func TestBraille(t *testing.T) {
+	frame := [width * height]byte{}
+
+	assert.Equal(t, braille(&frame, 0, 0), '⠀')
+
+	frame[0] = pixelOn
+	frame[width+1] = pixelOn
+	frame[3*width] = pixelOn
+	frame[3*width+1] = 0x20
+
+	assert.Equal(t, braille(&frame, 0, 0), '⡑')
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Size of the register and memory panels drawn next to the screen, in cells.
+const (
+	panelWidth  = 36
+	panelHeight = 21
+	panelGap    = 2
+)
+
+// Number of memory rows shown around an address, 8 bytes per row.
+const dumpRows = 4
+
+// statePanel returns the lines of text showing the registers, the stack, the
+// timers, the current instruction and its label, memory around PC and I and
+// the status of the debugger.
+func statePanel(state *State, debugger *Debugger) []string {
+	symbols := debugger.symbols()
+
+	op := uint16(0)
+	if int(state.PC)+1 < len(state.Memory) {
+		op = uint16(state.Memory[state.PC])<<8 | uint16(state.Memory[state.PC+1])
+	}
+
+	lines := []string{
+		fmt.Sprintf("PC %04X  I %04X  DT %02X  ST %02X", state.PC, state.I, state.DT, state.ST),
+		fmt.Sprintf("%04X  %04X  %s", state.PC, op, symbols.Disassemble(op)),
+		symbols.Location(state.PC),
+	}
+
+	for row := 0; row < 4; row++ {
+		registers := []string{}
+
+		for x := row * 4; x < row*4+4; x++ {
+			registers = append(registers, fmt.Sprintf("V%X %02X", x, state.V[x]))
+		}
+
+		lines = append(lines, strings.Join(registers, "  "))
+	}
+
+	lines = append(lines, stackLine(state))
+	lines = append(lines, "", "Memory at PC")
+	lines = append(lines, hexDump(&state.Memory, state.PC)...)
+	lines = append(lines, "", "Memory at I")
+	lines = append(lines, hexDump(&state.Memory, state.I)...)
+	lines = append(lines, debugger.Status())
+
+	return lines
+}
+
+// stackLine shows the return addresses on the stack, the innermost first,
+// trimmed to the width of the panel.
+func stackLine(state *State) string {
+	line := fmt.Sprintf("SP %X", state.SP)
+
+	for i := int(state.SP); i > 0 && i < len(state.Stack); i-- {
+		entry := fmt.Sprintf(" %04X", state.Stack[i])
+
+		if len(line)+len(entry) > panelWidth {
+			return line + " …"
+		}
+
+		line += entry
+	}
+
+	return line
+}
+
+// hexDump returns dumpRows rows of memory, starting with the row before the
+// one holding the address.
+func hexDump(memory *[memorySize]byte, address uint16) []string {
+	start := int(address&^7) - 8
+
+	if last := (len(memory)+7)&^7 - dumpRows*8; start > last {
+		start = last
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	lines := []string{}
+
+	for row := 0; row < dumpRows; row++ {
+		line := fmt.Sprintf("%04X ", start+row*8)
+
+		for column := 0; column < 8; column++ {
+			if i := start + row*8 + column; i < len(memory) {
+				line += fmt.Sprintf(" %02X", memory[i])
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatePanel(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.LoadProgram([]byte{0x60, 0x05, 0xA2, 0x34, 0x22, 0x00})
+	vm.Step()
+	vm.Step()
+	vm.Step()
+
+	state := vm.SaveState()
+	lines := statePanel(&state, nil)
+
+	assert.Equal(t, len(lines), panelHeight)
+	assert.Equal(t, lines[0], "PC 0200  I 0234  DT 00  ST 00")
+	assert.Equal(t, lines[1], "0200  6005  LD V0, 0x05")
+	assert.Equal(t, lines[2], "")
+	assert.Equal(t, lines[3], "V0 05  V1 00  V2 00  V3 00")
+	assert.Equal(t, lines[7], "SP 1 0204")
+	assert.Equal(t, lines[10], "01F8  00 00 00 00 00 00 00 00")
+	assert.Equal(t, lines[11], "0200  60 05 A2 34 22 00 00 00")
+	assert.Equal(t, lines[20], "")
+
+	symbols, _ := ParseSymbols(strings.NewReader("0x200 main\n0x200 game.8o:3\n"))
+	debugger := &Debugger{Symbols: symbols}
+	debugger.Pause()
+	lines = statePanel(&state, debugger)
+
+	assert.Equal(t, lines[2], "main game.8o:3")
+	assert.Equal(t, lines[20], "Paused")
+
+	for _, line := range lines {
+		assert.LessOrEqual(t, len([]rune(line)), panelWidth)
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	memory := [memorySize]byte{}
+
+	assert.Equal(t, hexDump(&memory, 0x000)[0], "0000  00 00 00 00 00 00 00 00")
+	assert.Equal(t, hexDump(&memory, 0xFFE), []string{
+		"0FE0  00 00 00 00 00 00 00 00",
+		"0FE8  00 00 00 00 00 00 00 00",
+		"0FF0  00 00 00 00 00 00 00 00",
+		"0FF8  00 00 00 00 00 00 00 00",
+	})
+}
+
+func TestStackLine(t *testing.T) {
+	state := State{SP: 15}
+	for i := range state.Stack {
+		state.Stack[i] = uint16(0x200 + i*2)
+	}
+
+	assert.Equal(t, stackLine(&state), "SP F 021E 021C 021A 0218 0216 0214 …")
+}
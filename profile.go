@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Number of entries in the hot spot table of a profile report.
+const hotSpots = 20
+
+// Profiler counts the instructions run per address, per opcode class and per
+// call stack, following subroutines through CALL and RET.
+type Profiler struct {
+	Total   uint64
+	Counts  map[uint16]uint64 // Instructions run at each address
+	Opcodes map[uint16]uint64 // Instructions run of each class, see opcodeClass
+	Calls   map[uint16]uint64 // Calls of each subroutine
+	Stacks  map[string]uint64 // Instructions run in each call stack, see stackKey
+
+	stack []uint16
+	key   string
+}
+
+// NewProfiler returns a profiler whose outermost frame is the entry point of
+// the program.
+func NewProfiler() *Profiler {
+	profiler := &Profiler{
+		Counts:  map[uint16]uint64{},
+		Opcodes: map[uint16]uint64{},
+		Calls:   map[uint16]uint64{},
+		Stacks:  map[string]uint64{},
+	}
+	profiler.setStack([]uint16{0x200})
+
+	return profiler
+}
+
+// Step counts an instruction that ran, meant to be registered with OnStep.
+func (profiler *Profiler) Step(pc, op uint16) {
+	profiler.Total++
+	profiler.Counts[pc]++
+	profiler.Opcodes[opcodeClass(op)]++
+	profiler.Stacks[profiler.key]++
+
+	switch {
+	case op&0xF000 == 0x2000:
+		address := op & 0x0FFF
+		profiler.Calls[address]++
+		profiler.setStack(append(profiler.stack, address))
+	case op == 0x00EE && len(profiler.stack) > 1:
+		profiler.setStack(profiler.stack[:len(profiler.stack)-1])
+	}
+}
+
+func (profiler *Profiler) setStack(stack []uint16) {
+	profiler.stack = stack
+	profiler.key = stackKey(stack)
+}
+
+// stackKey joins the addresses of a call stack, outermost first.
+func stackKey(stack []uint16) string {
+	frames := make([]string, len(stack))
+
+	for i, address := range stack {
+		frames[i] = fmt.Sprintf("%03X", address)
+	}
+
+	return strings.Join(frames, ";")
+}
+
+// parseStackKey returns the addresses of a key made by stackKey.
+func parseStackKey(key string) []uint16 {
+	stack := []uint16{}
+
+	for _, frame := range strings.Split(key, ";") {
+		var address uint16
+		fmt.Sscanf(frame, "%X", &address)
+		stack = append(stack, address)
+	}
+
+	return stack
+}
+
+// opcodeClass clears the operands of an opcode, keeping the bits that select
+// the instruction.
+func opcodeClass(op uint16) uint16 {
+	switch op & 0xF000 {
+	case 0x0000:
+		if op == 0x00E0 || op == 0x00EE {
+			return op
+		}
+	case 0x5000, 0x8000, 0x9000:
+		return op & 0xF00F
+	case 0xE000, 0xF000:
+		return op & 0xF0FF
+	}
+
+	return op & 0xF000
+}
+
+// opcodePattern names an opcode class the way references write it, like 8xy4.
+func opcodePattern(class uint16) string {
+	digits := []byte(fmt.Sprintf("%04X", class))
+
+	switch class & 0xF000 {
+	case 0x0000:
+		if class != 0x00E0 && class != 0x00EE {
+			return "0nnn"
+		}
+	case 0x1000, 0x2000, 0xA000, 0xB000:
+		return string(digits[0]) + "nnn"
+	case 0x3000, 0x4000, 0x6000, 0x7000, 0xC000:
+		return string(digits[0]) + "xkk"
+	case 0x5000, 0x8000, 0x9000:
+		return string(digits[0]) + "xy" + string(digits[3])
+	case 0xD000:
+		return "Dxyn"
+	case 0xE000, 0xF000:
+		return string(digits[0]) + "x" + string(digits[2:])
+	}
+
+	return string(digits)
+}
+
+// frameName names a subroutine by its label, or by its address.
+func frameName(symbols *Symbols, address uint16) string {
+	if name, offset, ok := symbols.Label(address); ok && offset == 0 {
+		return name
+	}
+
+	return fmt.Sprintf("0x%03X", address)
+}
+
+// WriteFolded writes the call stacks in the folded format read by flame
+// graph tools, one stack per line followed by its instruction count.
+func (profiler *Profiler) WriteFolded(writer io.Writer, symbols *Symbols) error {
+	lines := []string{}
+
+	for key, count := range profiler.Stacks {
+		frames := []string{}
+
+		for _, address := range parseStackKey(key) {
+			frames = append(frames, frameName(symbols, address))
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %d", strings.Join(frames, ";"), count))
+	}
+
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subroutineCycles returns the instructions run inside each subroutine,
+// including the ones it called, and the ones run by the subroutine itself.
+func (profiler *Profiler) subroutineCycles() (map[uint16]uint64, map[uint16]uint64) {
+	total := map[uint16]uint64{}
+	self := map[uint16]uint64{}
+
+	for key, count := range profiler.Stacks {
+		stack := parseStackKey(key)
+		seen := map[uint16]bool{}
+
+		for _, address := range stack {
+			// Recursive calls only count once
+			if !seen[address] {
+				total[address] += count
+				seen[address] = true
+			}
+		}
+
+		self[stack[len(stack)-1]] += count
+	}
+
+	return total, self
+}
+
+// sortedByCount returns the keys of counts, the highest counts first.
+func sortedByCount(counts map[uint16]uint64) []uint16 {
+	keys := []uint16{}
+
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// WriteReport writes the hot spots, opcode classes and subroutines of the
+// profile. Instructions are disassembled from memory as it is at the end.
+func (profiler *Profiler) WriteReport(writer io.Writer, memory *[memorySize]byte, symbols *Symbols) error {
+	table := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	percent := func(count uint64) string {
+		if profiler.Total == 0 {
+			return "0.0%"
+		}
+
+		return fmt.Sprintf("%.1f%%", float64(count)*100/float64(profiler.Total))
+	}
+
+	fmt.Fprintf(table, "Instructions run: %d\n\nHot spots\n", profiler.Total)
+	fmt.Fprintln(table, "Address\tCount\t%\tInstruction")
+
+	for i, address := range sortedByCount(profiler.Counts) {
+		if i == hotSpots {
+			break
+		}
+
+		instruction := ""
+		if int(address)+1 < len(memory) {
+			instruction = symbols.Disassemble(uint16(memory[address])<<8 | uint16(memory[address+1]))
+		}
+
+		count := profiler.Counts[address]
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\n", symbols.Describe(address), count, percent(count), instruction)
+	}
+
+	fmt.Fprintln(table, "\nOpcodes\nClass\tCount\t%\tInstruction")
+
+	for _, class := range sortedByCount(profiler.Opcodes) {
+		count := profiler.Opcodes[class]
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\n", opcodePattern(class), count, percent(count), strings.Fields(Disassemble(class))[0])
+	}
+
+	total, self := profiler.subroutineCycles()
+
+	fmt.Fprintln(table, "\nSubroutines\nSubroutine\tTotal\t%\tSelf\tCalls")
+
+	for _, address := range sortedByCount(total) {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%d\t%d\n", frameName(symbols, address), total[address], percent(total[address]), self[address], profiler.Calls[address])
+	}
+
+	return table.Flush()
+}
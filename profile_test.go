@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Calls a subroutine that calls another one, twice
+//
+//	0x200: CALL 0x208
+//	0x202: CALL 0x208
+//	0x204: JP 0x204
+//	0x206: (unused)
+//	0x208: ADD V0, 1
+//	0x20A: CALL 0x20E
+//	0x20C: RET
+//	0x20E: ADD V1, 1
+//	0x210: RET
+var profiledProgram = []byte{
+	0x22, 0x08, 0x22, 0x08, 0x12, 0x04, 0x00, 0x00,
+	0x70, 0x01, 0x22, 0x0E, 0x00, 0xEE, 0x71, 0x01, 0x00, 0xEE,
+}
+
+func profile(t *testing.T, steps int) *Profiler {
+	vm := InitHeadlessVM()
+	vm.LoadProgram(profiledProgram)
+	profiler := NewProfiler()
+	vm.OnStep(profiler.Step)
+
+	for i := 0; i < steps; i++ {
+		assert.Nil(t, vm.Step())
+	}
+
+	return profiler
+}
+
+func TestProfiler(t *testing.T) {
+	profiler := profile(t, 14)
+
+	assert.Equal(t, profiler.Total, uint64(14))
+	assert.Equal(t, profiler.Counts[0x208], uint64(2))
+	assert.Equal(t, profiler.Counts[0x204], uint64(2))
+	assert.Equal(t, profiler.Opcodes[0x2000], uint64(4))
+	assert.Equal(t, profiler.Opcodes[0x00EE], uint64(4))
+	assert.Equal(t, profiler.Calls[0x20E], uint64(2))
+
+	total, self := profiler.subroutineCycles()
+	assert.Equal(t, total[0x200], uint64(14))
+	assert.Equal(t, total[0x208], uint64(10))
+	assert.Equal(t, self[0x208], uint64(6))
+	assert.Equal(t, self[0x20E], uint64(4))
+}
+
+func TestProfilerFolded(t *testing.T) {
+	profiler := profile(t, 14)
+	symbols, _ := ParseSymbols(strings.NewReader("0x200 main\n0x208 update\n"))
+	folded := bytes.Buffer{}
+
+	assert.Nil(t, profiler.WriteFolded(&folded, symbols))
+	assert.Equal(t, folded.String(), "main 4\nmain;update 6\nmain;update;0x20E 4\n")
+}
+
+func TestProfilerReport(t *testing.T) {
+	profiler := profile(t, 14)
+	memory := [memorySize]byte{}
+	copy(memory[0x200:], profiledProgram)
+	report := bytes.Buffer{}
+
+	assert.Nil(t, profiler.WriteReport(&report, &memory, nil))
+	assert.Contains(t, report.String(), "Instructions run: 14")
+	assert.Contains(t, report.String(), "0x208    2      14.3%  ADD V0, 0x01")
+	assert.Contains(t, report.String(), "2nnn   4      28.6%  CALL")
+	assert.Contains(t, report.String(), "0x208       10     71.4%   6     2")
+}
+
+func TestOpcodePattern(t *testing.T) {
+	patterns := map[uint16]string{
+		0x00E0: "00E0",
+		0x0000: "0nnn",
+		0x1000: "1nnn",
+		0x6000: "6xkk",
+		0x8004: "8xy4",
+		0xD000: "Dxyn",
+		0xE09E: "Ex9E",
+		0xF055: "Fx55",
+	}
+
+	for class, expected := range patterns {
+		assert.Equal(t, opcodePattern(class), expected)
+	}
+
+	assert.Equal(t, opcodeClass(0x8A34), uint16(0x8004))
+	assert.Equal(t, opcodeClass(0xF355), uint16(0xF055))
+	assert.Equal(t, opcodeClass(0x6A12), uint16(0x6000))
+}
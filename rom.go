@@ -1,23 +1,320 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"image/gif"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-func ReadROM(rom io.Reader, size int) []byte {
+// Address programs are loaded at.
+const programStart = 0x200
+
+// Largest archive read into memory to find the ROM inside.
+const maxArchiveSize = 16 << 20
+
+// Platform is a CHIP-8 variant, which sets how the instructions the variants
+// disagree on behave.
+type Platform struct {
+	Name      string
+	Extension string // Extension of the ROMs written for it
+	Quirks    Quirks
+}
+
+// PlatformCHIP8 is the original CHIP-8 run by the VM.
+var PlatformCHIP8 = Platform{Name: "CHIP-8", Extension: ".ch8"}
+
+// PlatformSCHIP is SUPER-CHIP, which adds a high resolution mode.
+var PlatformSCHIP = Platform{Name: "SCHIP", Extension: ".sc8", Quirks: Quirks{JumpVx: true}}
+
+// PlatformXOCHIP is Octo's XO-CHIP, which adds colors, sound and 64K of memory.
+var PlatformXOCHIP = Platform{Name: "XO-CHIP", Extension: ".xo8", Quirks: Quirks{ShiftVy: true, IncrementI: true}}
+
+var platforms = []Platform{PlatformCHIP8, PlatformSCHIP, PlatformXOCHIP}
+
+// Size of the largest program that fits in memory. The VM runs every platform
+// in the 4K of the original, so SCHIP and XO-CHIP programs that need more
+// memory can't be loaded.
+const maxROMSize = memorySize - programStart
+
+// platformForName returns the platform of a ROM from the extension of its
+// file name, which is CHIP-8 for anything but .sc8 and .xo8.
+func platformForName(name string) (Platform, bool) {
+	extension := strings.ToLower(filepath.Ext(name))
+
+	for _, platform := range platforms {
+		if platform.Extension == extension {
+			return platform, true
+		}
+	}
+
+	return PlatformCHIP8, false
+}
+
+// ROM is a program and the platform it was written for.
+type ROM struct {
+	Name     string // File name, the one inside the archive for zip and gzip files
+	Program  []byte
+	Platform Platform
+	Symbols  *Symbols // Labels and lines of cartridges, nil for other ROMs
+}
+
+// Signatures of common files that are mistaken for ROMs. Most of them also
+// decode to valid instructions, so only magics a real ROM is unlikely to start
+// with are kept: 4 bytes or more, or an invalid first instruction like JPEG's
+// 0xFFD8. Shorter ones such as "MZ" or "#!" are left out. GIF, zip and gzip
+// files are unpacked before these are checked.
+var fileSignatures = []struct {
+	magic string
+	kind  string
+}{
+	{"\x89PNG\r\n\x1A\n", "PNG image"},
+	{"\xFF\xD8\xFF", "JPEG image"},
+	{"\x7FELF", "Linux executable"},
+	{"\xCF\xFA\xED\xFE", "macOS executable"},
+	{"%PDF-", "PDF document"},
+}
+
+// notPacked is returned by the unpackers for data that only starts like their
+// format, which is then read as a raw ROM if it fits.
+type notPacked struct {
+	error
+}
+
+// unpacker returns the reader of the format a ROM is packed in, found by its
+// magic, or nil for raw ROMs.
+func unpacker(magic []byte) func(data []byte, name string) (*ROM, error) {
+	switch {
+	case bytes.HasPrefix(magic, []byte("GIF8")):
+		return readCartridge
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return readZip
+	case bytes.HasPrefix(magic, []byte("\x1F\x8B")):
+		return readGzip
+	}
+
+	return nil
+}
+
+// ReadROM reads a program from a stream of any length. Octo cartridges, zip
+// archives and gzip files are unpacked, everything else is read as it is and
+// checked to fit in memory and not be some other kind of file. ROMs that only
+// start like a packed file, such as one beginning with JP 0xF8B, are read as
+// they are. The platform comes from the extension of name, the file the ROM
+// was read from.
+func ReadROM(rom io.Reader, name string) (*ROM, error) {
 	reader := bufio.NewReader(rom)
-	buffer := make([]byte, size)
+	magic, _ := reader.Peek(4)
 
-	for {
-		_, err := reader.Read(buffer)
+	if unpack := unpacker(magic); unpack != nil {
+		data, err := ioutil.ReadAll(io.LimitReader(reader, maxArchiveSize+1))
 
 		if err != nil {
-			if err == io.EOF {
-				break
+			return nil, err
+		}
+
+		if len(data) > maxArchiveSize {
+			return nil, fmt.Errorf("Archive is too large: can't be larger than %d bytes", maxArchiveSize)
+		}
+
+		rom, err := unpack(data, name)
+
+		if packed, ok := err.(notPacked); ok {
+			if len(data) > maxROMSize {
+				return nil, packed.error
 			}
+
+			return readRawROM(data, name)
+		}
+
+		return rom, err
+	}
+
+	// One byte more than fits is enough to tell that the ROM is too large
+	program, err := ioutil.ReadAll(io.LimitReader(reader, maxROMSize+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return readRawROM(program, name)
+}
+
+// readRawROM checks that a program fits in memory and isn't some other kind
+// of file.
+func readRawROM(program []byte, name string) (*ROM, error) {
+	platform, _ := platformForName(name)
+
+	if len(program) == 0 {
+		return nil, errors.New("ROM is empty")
+	}
+
+	if len(program) > maxROMSize {
+		return nil, fmt.Errorf("ROM is too large: programs can't be larger than %d bytes", maxROMSize)
+	}
+
+	for _, signature := range fileSignatures {
+		if bytes.HasPrefix(program, []byte(signature.magic)) {
+			return nil, fmt.Errorf("Not a CHIP-8 ROM: this looks like a %s", signature.kind)
+		}
+	}
+
+	if isText(program) {
+		return nil, errors.New("Not a CHIP-8 ROM: this looks like a text file, source code needs to be assembled first")
+	}
+
+	return &ROM{Name: name, Program: program, Platform: platform}, nil
+}
+
+// readCartridge reads an Octo cartridge. They hold the source code of the
+// program rather than the program itself, so only cartridges whose source is
+// plain bytes can be loaded. Others are refused with their platform.
+func readCartridge(data []byte, name string) (*ROM, error) {
+	images, err := gif.DecodeAll(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, notPacked{err}
+	}
+
+	cartridge, err := cartridgeFromGIF(images)
+
+	if err != nil {
+		return nil, errors.New("Not a CHIP-8 ROM: this looks like a GIF image")
+	}
+
+	program, symbols, err := cartridge.Bytecode(filepath.Base(name))
+
+	if err != nil {
+		return nil, fmt.Errorf("This Octo cartridge holds %s source code, which needs to be assembled with Octo first", cartridge.Options.Platform().Name)
+	}
+
+	if len(program) == 0 {
+		return nil, errors.New("ROM is empty")
+	}
+
+	if len(program) > maxROMSize {
+		return nil, fmt.Errorf("ROM is too large: programs can't be larger than %d bytes", maxROMSize)
+	}
+
+	return &ROM{Name: name, Program: program, Platform: cartridge.Options.Platform(), Symbols: symbols}, nil
+}
+
+// readZip reads the only ROM of a zip archive, found by its extension.
+func readZip(archive []byte, name string) (*ROM, error) {
+	files, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+
+	if err != nil {
+		return nil, notPacked{err}
+	}
+
+	roms := []*zip.File{}
+
+	for _, file := range files.File {
+		if _, ok := platformForName(file.Name); ok && !file.FileInfo().IsDir() && !strings.HasPrefix(file.Name, "__MACOSX/") {
+			roms = append(roms, file)
+		}
+	}
+
+	switch len(roms) {
+	case 0:
+		return nil, errors.New("No .ch8, .sc8 or .xo8 ROM in the archive")
+	case 1:
+	default:
+		names := []string{}
+		for _, file := range roms {
+			names = append(names, file.Name)
+		}
+
+		return nil, fmt.Errorf("The archive holds %d ROMs, extract the one to run: %s", len(roms), strings.Join(names, ", "))
+	}
+
+	file, err := roms[0].Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadROM(file, roms[0].Name)
+}
+
+// readGzip reads a gzipped ROM, named in the gzip header or by dropping .gz
+// from the name of the file.
+func readGzip(data []byte, name string) (*ROM, error) {
+	file, err := gzip.NewReader(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, notPacked{err}
+	}
+
+	defer file.Close()
+
+	if file.Name != "" {
+		name = file.Name
+	} else {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	// One byte more than fits is enough to tell that the ROM is too large
+	program, err := ioutil.ReadAll(io.LimitReader(file, maxROMSize+1))
+
+	if err != nil {
+		return nil, notPacked{err}
+	}
+
+	return ReadROM(bytes.NewReader(program), name)
+}
+
+// isText reports whether the program is made of lines of printable ASCII.
+// Instructions almost always have bytes outside of that range.
+func isText(program []byte) bool {
+	if !bytes.ContainsRune(program, '\n') {
+		return false
+	}
+
+	for _, b := range program {
+		if (b < 0x20 || b > 0x7E) && b != '\n' && b != '\r' && b != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readROMFile reads the ROM at path, or from stdin when path is "-".
+func readROMFile(path string) (*ROM, error) {
+	if path == "-" {
+		rom, err := ReadROM(os.Stdin, "")
+
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %s", err)
 		}
+
+		return rom, nil
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	rom, err := ReadROM(file, path)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
 	}
 
-	return buffer
+	return rom, nil
 }
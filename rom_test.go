@@ -1,7 +1,17 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,9 +19,190 @@ import (
 
 func TestReadROM(t *testing.T) {
 	buf := bytes.Buffer{}
-	buf.Write([]uint8("test"))
+	buf.Write([]uint8{0x00, 0xE0, 0x12, 0x00})
 
-	result := ReadROM(&buf, 4)
+	result, err := ReadROM(&buf, "game.ch8")
 
-	assert.Equal(t, result, []byte("test"))
+	assert.Nil(t, err)
+	assert.Equal(t, result.Program, []byte{0x00, 0xE0, 0x12, 0x00})
+	assert.Equal(t, result.Platform, PlatformCHIP8)
+}
+
+func TestReadROMPlatform(t *testing.T) {
+	platforms := map[string]Platform{
+		"game.ch8":      PlatformCHIP8,
+		"GAME.SC8":      PlatformSCHIP,
+		"dir/game.xo8":  PlatformXOCHIP,
+		"game":          PlatformCHIP8,
+		"game.unknown8": PlatformCHIP8,
+	}
+
+	for name, platform := range platforms {
+		result, err := ReadROM(bytes.NewReader([]byte{0x00, 0xE0}), name)
+
+		assert.Nil(t, err)
+		assert.Equal(t, result.Platform, platform)
+	}
+
+	// XO-CHIP programs only load if they fit in the memory of the VM
+	_, err := ReadROM(bytes.NewReader(make([]byte, 3585)), "game.xo8")
+	assert.Equal(t, err.Error(), "ROM is too large: programs can't be larger than 3584 bytes")
+}
+
+func TestReadROMSize(t *testing.T) {
+	largest := make([]byte, maxROMSize)
+
+	result, err := ReadROM(bytes.NewReader(largest), "game.ch8")
+	assert.Nil(t, err)
+	assert.Equal(t, len(result.Program), 3584)
+
+	_, err = ReadROM(bytes.NewReader(append(largest, 0)), "game.ch8")
+	assert.Equal(t, err.Error(), "ROM is too large: programs can't be larger than 3584 bytes")
+
+	_, err = ReadROM(bytes.NewReader(nil), "game.ch8")
+	assert.Equal(t, err.Error(), "ROM is empty")
+}
+
+// failingReader fails after returning some data.
+type failingReader struct {
+	read bool
+}
+
+func (reader *failingReader) Read(p []byte) (int, error) {
+	if reader.read {
+		return 0, errors.New("Disk on fire")
+	}
+
+	reader.read = true
+
+	return copy(p, []byte{0x00, 0xE0}), nil
+}
+
+func TestReadROMError(t *testing.T) {
+	_, err := ReadROM(&failingReader{}, "game.ch8")
+
+	assert.Equal(t, err.Error(), "Disk on fire")
+}
+
+func TestReadROMFormat(t *testing.T) {
+	files := map[string]string{
+		"\x89PNG\r\n\x1a\n":        "Not a CHIP-8 ROM: this looks like a PNG image",
+		"\x7fELF\x02\x01":          "Not a CHIP-8 ROM: this looks like a Linux executable",
+		": main\n  v0 := 5\n":      "Not a CHIP-8 ROM: this looks like a text file, source code needs to be assembled first",
+		"\x00\xE0\xA2\x20\x12\x00": "",
+		"#!\x00\xE0\x12\x00":       "",
+		"MZ\x00\xE0\x12\x00":       "",
+		gifImage(t):                "Not a CHIP-8 ROM: this looks like a GIF image",
+
+		// ROMs that only start like a GIF, zip or gzip file
+		"GIF8\x60\x05\x12\x04": "",
+		"PK\x03\x04\x12\x04":   "",
+		"\x1F\x8B\x60\x05":     "",
+	}
+
+	for contents, expected := range files {
+		_, err := ReadROM(strings.NewReader(contents), "game.ch8")
+
+		if expected == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, err.Error(), expected)
+		}
+	}
+
+	// Too large for a raw ROM, so the zip error stays
+	_, err := ReadROM(bytes.NewReader(append([]byte("PK\x03\x04"), make([]byte, 4000)...)), "game.zip")
+	assert.EqualError(t, err, "zip: not a valid zip file")
+}
+
+// gifImage returns a GIF that isn't an Octo cartridge.
+func gifImage(t *testing.T) string {
+	buf := bytes.Buffer{}
+	img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black, color.White})
+
+	assert.Nil(t, gif.Encode(&buf, img, nil))
+
+	return buf.String()
+}
+
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	buf := bytes.Buffer{}
+	archive := zip.NewWriter(&buf)
+
+	for name, contents := range files {
+		file, err := archive.Create(name)
+		assert.Nil(t, err)
+
+		_, err = file.Write(contents)
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, archive.Close())
+
+	return buf.Bytes()
+}
+
+func TestReadROMZip(t *testing.T) {
+	archive := zipArchive(t, map[string][]byte{
+		"README.txt":        []byte("Have fun\n"),
+		"games/octojam.sc8": {0x00, 0xFF, 0x12, 0x00},
+	})
+
+	result, err := ReadROM(bytes.NewReader(archive), "octojam.zip")
+
+	assert.Nil(t, err)
+	assert.Equal(t, result.Name, "games/octojam.sc8")
+	assert.Equal(t, result.Program, []byte{0x00, 0xFF, 0x12, 0x00})
+	assert.Equal(t, result.Platform, PlatformSCHIP)
+
+	archive = zipArchive(t, map[string][]byte{"README.txt": []byte("Have fun\n")})
+	_, err = ReadROM(bytes.NewReader(archive), "octojam.zip")
+	assert.Equal(t, err.Error(), "No .ch8, .sc8 or .xo8 ROM in the archive")
+
+	archive = zipArchive(t, map[string][]byte{"a.ch8": {0x00, 0xE0}, "b.ch8": {0x00, 0xE0}})
+	_, err = ReadROM(bytes.NewReader(archive), "octojam.zip")
+	assert.Contains(t, err.Error(), "The archive holds 2 ROMs, extract the one to run")
+}
+
+func TestReadROMGzip(t *testing.T) {
+	buf := bytes.Buffer{}
+	file := gzip.NewWriter(&buf)
+	file.Write([]byte{0x00, 0xE0, 0x12, 0x00})
+	file.Close()
+
+	result, err := ReadROM(bytes.NewReader(buf.Bytes()), "dir/game.xo8.gz")
+
+	assert.Nil(t, err)
+	assert.Equal(t, result.Name, "dir/game.xo8")
+	assert.Equal(t, result.Program, []byte{0x00, 0xE0, 0x12, 0x00})
+	assert.Equal(t, result.Platform, PlatformXOCHIP)
+
+	// The name in the gzip header wins
+	buf.Reset()
+	file = gzip.NewWriter(&buf)
+	file.Name = "game.sc8"
+	file.Write([]byte{0x00, 0xE0})
+	file.Close()
+
+	result, err = ReadROM(bytes.NewReader(buf.Bytes()), "download.gz")
+
+	assert.Nil(t, err)
+	assert.Equal(t, result.Name, "game.sc8")
+	assert.Equal(t, result.Platform, PlatformSCHIP)
+}
+
+func TestReadROMFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "game.ch8")
+	assert.Nil(t, ioutil.WriteFile(path, []byte{}, 0644))
+
+	_, err = readROMFile(path)
+	assert.Equal(t, err.Error(), path+": ROM is empty")
+
+	rom, err := readROMFile("roms/maze_demo.ch8")
+	assert.Nil(t, err)
+	assert.Equal(t, len(rom.Program), 38)
 }
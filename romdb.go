@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// ROMInfo describes a known ROM and the settings it plays best with.
+type ROMInfo struct {
+	Title    string
+	Author   string
+	Platform Platform
+	Quirks   Quirks
+	TickRate int             // Instructions per second, 0 for the default
+	Keys     map[Button]byte // Keypad keys pressed by the arrow keys and space
+	Colors   string          // Recommended theme, see ParsePalette
+}
+
+// vipTickRate is the speed games written for the COSMAC VIP play at, 7
+// instructions a frame as in Octo.
+const vipTickRate = 7 * int(frameSpeed)
+
+// romDatabase holds the known ROMs by the SHA-1 of their contents.
+var romDatabase = map[string]ROMInfo{
+	"193915dcde1365ae054c4eaa21a35baa27cd3356": {
+		Title:    "Breakout",
+		Author:   "Carmelo Cortez",
+		Platform: PlatformCHIP8,
+		Quirks:   QuirksVIP,
+		TickRate: vipTickRate,
+		Keys:     map[Button]byte{ButtonLeft: 0x4, ButtonRight: 0x6},
+	},
+	"0ebc4b92c6059d6193565644fb00108161d03d23": {
+		Title:    "Keypad Test",
+		Author:   "Hap",
+		Platform: PlatformCHIP8,
+	},
+	"72e8f3a10a32bd7fb91322ecab87249f95e81e57": {
+		Title:    "Lunar Lander",
+		Author:   "Udo Pernisz",
+		Platform: PlatformCHIP8,
+		Quirks:   QuirksVIP,
+		TickRate: vipTickRate,
+	},
+	"8b70080adbac44513ec60005734a816372b845ec": {
+		Title:    "Maze",
+		Author:   "David Winter",
+		Platform: PlatformCHIP8,
+		Colors:   "white",
+	},
+	"a82ca5c53e1dcedfab4f65efef02229145771b7d": {
+		Title:    "Chip-8 Picture",
+		Platform: PlatformCHIP8,
+		Colors:   "white",
+	},
+	"f1e036fb93b482b1ddfcb2bc1a4de43c8cf51def": {
+		Title:    "Random Number Test",
+		Author:   "Matthew Mikolay",
+		Platform: PlatformCHIP8,
+		Keys:     map[Button]byte{ButtonAction: 0x0},
+	},
+}
+
+// String returns the title and author of the ROM.
+func (info ROMInfo) String() string {
+	if info.Author == "" {
+		return info.Title
+	}
+
+	return info.Title + " by " + info.Author
+}
+
+// LookupROM returns what the database knows about a ROM. Unknown ROMs get the
+// quirks of the platform they were loaded as.
+func LookupROM(rom *ROM) (ROMInfo, bool) {
+	sum := sha1.Sum(rom.Program)
+
+	if info, ok := romDatabase[hex.EncodeToString(sum[:])]; ok {
+		return info, true
+	}
+
+	return ROMInfo{Title: rom.Name, Platform: rom.Platform, Quirks: rom.Platform.Quirks}, false
+}
+
+// Configure sets up the VM the way the ROM plays best.
+func (vm *VM) Configure(info ROMInfo) {
+	vm.Quirks = info.Quirks
+	vm.Buttons = info.Keys
+	vm.SetTickRate(info.TickRate)
+}
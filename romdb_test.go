@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupROM(t *testing.T) {
+	rom, err := readROMFile("roms/breakout.ch8")
+	assert.Nil(t, err)
+
+	info, known := LookupROM(rom)
+	assert.Equal(t, known, true)
+	assert.Equal(t, info.String(), "Breakout by Carmelo Cortez")
+	assert.Equal(t, info.Quirks, QuirksVIP)
+
+	vm := InitVM()
+	defer vm.Close()
+	vm.Configure(info)
+
+	assert.Equal(t, vm.Quirks, QuirksVIP)
+	assert.Equal(t, vm.TickRate, 420)
+	assert.Equal(t, vm.Buttons[ButtonLeft], uint8(0x4))
+}
+
+func TestKnownSCHIPROM(t *testing.T) {
+	// Not a real ROM, no SCHIP ROM is bundled
+	rom := &ROM{Name: "game.sc8", Program: []byte{0x12, 0x00}, Platform: PlatformSCHIP}
+	romDatabase["92a5652d382a18e89c4881ec57041fc7d885ca80"] = ROMInfo{
+		Title:    "Game",
+		Platform: PlatformSCHIP,
+		Quirks:   Quirks{JumpVx: true},
+		TickRate: 1200,
+		Colors:   "amber",
+	}
+	defer delete(romDatabase, "92a5652d382a18e89c4881ec57041fc7d885ca80")
+
+	info, known := LookupROM(rom)
+	assert.Equal(t, known, true)
+	assert.Equal(t, info.Colors, "amber")
+
+	env := NewEnv(rom)
+	env.Reset()
+	assert.Equal(t, env.vm.Quirks, Quirks{JumpVx: true})
+	assert.Equal(t, env.vm.TickRate, 1200)
+}
+
+func TestLookupUnknownROM(t *testing.T) {
+	info, known := LookupROM(&ROM{Name: "game.sc8", Program: []byte{0x12, 0x00}, Platform: PlatformSCHIP})
+
+	assert.Equal(t, known, false)
+	assert.Equal(t, info.String(), "game.sc8")
+	assert.Equal(t, info.Platform, PlatformSCHIP)
+	assert.Equal(t, info.Quirks, Quirks{JumpVx: true})
+}
+
+func TestROMDatabase(t *testing.T) {
+	for sum, info := range romDatabase {
+		assert.Len(t, sum, 40)
+		assert.NotEmpty(t, info.Title)
+
+		if info.Colors != "" {
+			_, err := ParsePalette(info.Colors)
+			assert.Nil(t, err, info.Title)
+		}
+	}
+}
@@ -1,55 +1,46 @@
 package main
 
-import (
-	"fmt"
-	"os"
-
-	"github.com/nsf/termbox-go"
-)
-
 const (
 	width  = 64
 	height = 32
 )
 
-// Contains the pixels on screen and implements screen render related functions
+// Contains the pixels on screen and tracks which rows changed since they were
+// last shown by the display
 type Screen struct {
 	Pixels [width * height]byte
-}
-
-func (screen *Screen) Init() {
-	err := termbox.Init()
-
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-}
+	Filter Filter
 
-func (screen *Screen) Close() {
-	termbox.Close()
+	dirty [height]bool // Rows changed since the last frame
 }
 
 func (screen *Screen) Clear() {
 	for i := range screen.Pixels {
 		screen.Pixels[i] = 0
 	}
+
+	for row := range screen.dirty {
+		screen.dirty[row] = true
+	}
 }
 
+// WriteSprite XORs the rows of a sprite onto the screen at x, y, wrapping
+// around the edges, and reports whether any pixel was turned off.
 func (screen *Screen) WriteSprite(sprite []byte, x, y byte) bool {
 	collision := false
-	spriteHeight := len(sprite)
 
-	// fmt.Printf("Sprite: %b, X: %d, Y: %d\n", sprite, x, y)
-	for yline := 0; yline < spriteHeight; yline++ {
-		pixel := sprite[yline]
-		// fmt.Printf("Pixel: %x\n", pixel)
+	for yline, pixel := range sprite {
+		if pixel == 0 {
+			continue
+		}
+
+		row := (int(y) + yline) % height
+		start := row * width
 
 		for xline := 0; xline < 8; xline++ {
 			if (pixel & (0x80 >> xline)) != 0 {
-				position := (((int(x) + xline) % width) + ((int(y) + yline) * width)) % (width * height)
+				position := start + (int(x)+xline)%width
 
-				// fmt.Printf("Pos x: %d, Pos y: %d, Real: %d\n", int(x)+xline, int(y)+yline, position)
 				if screen.Pixels[position] == 0x01 {
 					collision = true
 				}
@@ -57,23 +48,43 @@ func (screen *Screen) WriteSprite(sprite []byte, x, y byte) bool {
 				screen.Pixels[position] ^= 1
 			}
 		}
+
+		screen.dirty[row] = true
 	}
 
 	return collision
 }
 
-func (screen *Screen) Render() {
-	for row := 0; row < height; row++ {
-		for pixel := 0; pixel < width; pixel++ {
-			v := ' '
-			coord := row*width + pixel
-
-			if screen.Pixels[coord] == 0x01 {
-				v = '█'
-			}
-			termbox.SetCell(pixel, row, v, termbox.ColorGreen, termbox.ColorBlack)
+// Dirty reports whether any pixel changed since the last render.
+func (screen *Screen) Dirty() bool {
+	for _, dirty := range screen.dirty {
+		if dirty {
+			return true
 		}
 	}
 
-	termbox.Flush()
+	return false
+}
+
+// Settled reports whether rendering again would draw the same frame, which is
+// not the case while a filter is still fading pixels in or out.
+func (screen *Screen) Settled() bool {
+	return screen.filter().Settled()
+}
+
+// Frame applies the filter to the pixels and returns the intensities the
+// display should draw.
+func (screen *Screen) Frame() *[width * height]byte {
+	frame := screen.filter().Apply(&screen.Pixels)
+	screen.dirty = [height]bool{}
+
+	return &frame
+}
+
+func (screen *Screen) filter() Filter {
+	if screen.Filter == nil {
+		screen.Filter = &NoFilter{}
+	}
+
+	return screen.Filter
 }
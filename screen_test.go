@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenDirty(t *testing.T) {
+	screen := Screen{}
+	assert.False(t, screen.Dirty())
+
+	screen.WriteSprite([]byte{0x80, 0x00, 0x80}, 4, 31)
+
+	assert.True(t, screen.Dirty())
+	assert.Equal(t, screen.dirty[31], true)
+	assert.Equal(t, screen.dirty[0], false)
+	assert.Equal(t, screen.dirty[1], true)
+
+	screen.dirty = [height]bool{}
+	screen.Clear()
+
+	assert.Equal(t, screen.dirty, [height]bool{
+		true, true, true, true, true, true, true, true,
+		true, true, true, true, true, true, true, true,
+		true, true, true, true, true, true, true, true,
+		true, true, true, true, true, true, true, true,
+	})
+}
+
+func TestWriteSpriteWraps(t *testing.T) {
+	screen := Screen{}
+
+	assert.Equal(t, screen.WriteSprite([]byte{0xC3, 0x81}, 60, 31), false)
+
+	assert.Equal(t, screen.Pixels[31*width+60], byte(1))
+	assert.Equal(t, screen.Pixels[31*width+61], byte(1))
+	assert.Equal(t, screen.Pixels[31*width+2], byte(1))
+	assert.Equal(t, screen.Pixels[31*width+3], byte(1))
+	assert.Equal(t, screen.Pixels[60], byte(1))
+	assert.Equal(t, screen.Pixels[3], byte(1))
+	assert.Equal(t, screen.Pixels[61], byte(0))
+
+	assert.Equal(t, screen.WriteSprite([]byte{0x80}, 60, 31), true)
+	assert.Equal(t, screen.Pixels[31*width+60], byte(0))
+}
@@ -0,0 +1,59 @@
+package main
+
+import "fmt"
+
+// SelfModification detects self-modifying code: Fx33 and Fx55 writing over
+// instructions that already ran, and execution reaching memory written at
+// runtime. Every address is reported once per kind.
+type SelfModification struct {
+	Symbols   *Symbols
+	OnWarning func(warning string)
+
+	executed       [memorySize]bool
+	written        [memorySize]bool
+	warnedWrite    [memorySize]bool
+	warnedExecuted [memorySize]bool
+}
+
+// Record checks an instruction after it ran, meant to be called from OnStep.
+func (smc *SelfModification) Record(vm *VM, pc, op uint16) {
+	for _, address := range []int{int(pc), int(pc) + 1} {
+		if address >= len(smc.executed) {
+			continue
+		}
+
+		if smc.written[address] && !smc.warnedExecuted[address] {
+			smc.warnedExecuted[address] = true
+			smc.warn(fmt.Sprintf("Executing %s, which was written at runtime", smc.Symbols.Describe(uint16(address))))
+		}
+
+		smc.executed[address] = true
+	}
+
+	var length int
+
+	switch op & 0xF0FF {
+	case 0xF033: // LD B, Vx
+		length = 3
+	case 0xF055: // LD [I], Vx
+		length = int(op&0x0F00>>8) + 1
+	}
+
+	start := int(vm.indexBefore(op))
+
+	for address := start; address < start+length && address < len(smc.written); address++ {
+		smc.written[address] = true
+
+		if smc.executed[address] && !smc.warnedWrite[address] {
+			smc.warnedWrite[address] = true
+			smc.warn(fmt.Sprintf("%s (%s) overwrote code at %s",
+				smc.Symbols.Describe(pc), Disassemble(op), smc.Symbols.Describe(uint16(address))))
+		}
+	}
+}
+
+func (smc *SelfModification) warn(warning string) {
+	if smc.OnWarning != nil {
+		smc.OnWarning(warning)
+	}
+}
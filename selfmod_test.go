@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfModification(t *testing.T) {
+	// Overwrites its own JP with "ADD V0, 1" and runs into it
+	//
+	//	0x200: LD V0, 0x70
+	//	0x202: LD V1, 0x01
+	//	0x204: LD I, 0x20A
+	//	0x206: LD [I], V1
+	//	0x208: JP 0x20A
+	//	0x20A: JP 0x20A, overwritten with ADD V0, 1
+	//	0x20C: JP 0x20C
+	vm := InitHeadlessVM()
+	vm.LoadProgram([]byte{0x60, 0x70, 0x61, 0x01, 0xA2, 0x0A, 0xF1, 0x55, 0x12, 0x0A, 0x12, 0x0A, 0x12, 0x0C})
+
+	warnings := []string{}
+	smc := &SelfModification{OnWarning: func(warning string) {
+		warnings = append(warnings, warning)
+	}}
+	vm.OnStep(func(pc, op uint16) {
+		smc.Record(vm, pc, op)
+	})
+
+	for i := 0; i < 8; i++ {
+		assert.Nil(t, vm.Step())
+	}
+
+	assert.Equal(t, vm.V[0], uint8(0x71))
+	assert.Equal(t, warnings, []string{
+		"Executing 0x20A, which was written at runtime",
+		"Executing 0x20B, which was written at runtime",
+	})
+
+	// Writing over code that already ran
+	vm.I = 0x200
+	vm.PC = 0x206
+	assert.Nil(t, vm.Step())
+	assert.Nil(t, vm.Step())
+	vm.PC = 0x206
+	assert.Nil(t, vm.Step())
+
+	assert.Equal(t, warnings[2:], []string{
+		"0x206 (LD [I], V1) overwrote code at 0x200",
+		"0x206 (LD [I], V1) overwrote code at 0x201",
+	})
+}
@@ -0,0 +1,148 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// sessionWriteTimeout is how long a client can go without reading before its
+// session ends.
+var sessionWriteTimeout = 10 * time.Second
+
+func init() {
+	commands["serve"] = serve
+}
+
+// serve hosts a session of the ROM for every connection, drawing on the
+// terminal of the client with ANSI escape sequences.
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":2323", "Address to listen on")
+	path := flags.String("rom", "", "Path to the chip8 rom")
+	display := addDisplayFlags(flags)
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("Provide path to rom.\nExample: chip8 serve --addr :2323 --rom ./breakout.ch8")
+	}
+
+	rom, err := readROMFile(*path)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := NewFilter(display.filter); err != nil {
+		return err
+	}
+
+	info, _ := LookupROM(rom)
+	config, err := display.config(*path, info.Colors)
+
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	logger.Println("Serving", *path, "on", listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			logger.Println(conn.RemoteAddr(), "connected")
+
+			if err := serveSession(conn, rom, display.filter, config); err != nil {
+				logger.Println(conn.RemoteAddr(), err)
+			}
+
+			logger.Println(conn.RemoteAddr(), "disconnected")
+		}()
+	}
+}
+
+// serveSession runs a VM for a single connection until the client quits,
+// disconnects or stops reading. A panic only ends this session.
+func serveSession(conn net.Conn, rom *ROM, filterName string, config Config) (err error) {
+	defer conn.Close()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("Session panicked: %v", recovered)
+		}
+	}()
+
+	frontend, err := NewANSI(sessionConn{conn}, config)
+
+	if err != nil {
+		return err
+	}
+
+	if err := frontend.Init(); err != nil {
+		return err
+	}
+	defer frontend.Close()
+
+	filter, err := NewFilter(filterName)
+
+	if err != nil {
+		return err
+	}
+
+	screen := Screen{Filter: filter}
+
+	info, _ := LookupROM(rom)
+	vm := InitVM()
+	defer vm.Close()
+
+	vm.SetScreen(&screen)
+	vm.Configure(info)
+	vm.Display = frontend
+	vm.Input = frontend
+	vm.Logger.SetOutput(ioutil.Discard)
+
+	if err := vm.LoadProgram(rom.Program); err != nil {
+		return err
+	}
+
+	go vm.EventListener()
+
+	return vm.Start()
+}
+
+// sessionConn gives every write to the client a deadline, and closes the
+// connection when a write fails, which ends the session.
+type sessionConn struct {
+	net.Conn
+}
+
+func (conn sessionConn) Write(p []byte) (int, error) {
+	conn.SetWriteDeadline(time.Now().Add(sessionWriteTimeout))
+
+	n, err := conn.Conn.Write(p)
+
+	if err != nil {
+		conn.Conn.Close()
+	}
+
+	return n, err
+}
@@ -0,0 +1,76 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeSession(t *testing.T) {
+	server, client := net.Pipe()
+	done := make(chan error)
+
+	// CLS, then draw the font sprite of 0 and loop forever
+	rom := &ROM{Program: []byte{0x00, 0xE0, 0xD0, 0x05, 0x12, 0x04}}
+
+	go func() {
+		done <- serveSession(server, rom, "none", Config{Palette: Themes["green"], Colors: "16"})
+	}()
+
+	negotiation := make([]byte, 9)
+	_, err := client.Read(negotiation)
+	assert.Nil(t, err)
+	assert.Equal(t, negotiation[0:3], []byte{telnetIAC, telnetWILL, telnetEcho})
+
+	output := bytes.Buffer{}
+
+	// Wait for the sprite to be drawn
+	for !bytes.Contains(output.Bytes(), []byte("\x1b[1;1H")) {
+		buffer := make([]byte, 4096)
+		n, err := client.Read(buffer)
+		assert.Nil(t, err)
+		output.Write(buffer[:n])
+	}
+
+	go ioutil.ReadAll(client)
+
+	_, err = client.Write([]byte{ctrlC})
+	assert.Nil(t, err)
+
+	assert.Nil(t, <-done)
+}
+
+func TestServeSessionStalledClient(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	done := make(chan error)
+
+	timeout := sessionWriteTimeout
+	sessionWriteTimeout = 50 * time.Millisecond
+	defer func() { sessionWriteTimeout = timeout }()
+
+	// Draw the font sprite of 0 and loop forever
+	rom := &ROM{Program: []byte{0xD0, 0x05, 0x12, 0x02}}
+
+	go func() {
+		done <- serveSession(server, rom, "none", Config{Palette: Themes["green"], Colors: "16"})
+	}()
+
+	// Read the telnet negotiation and the clear screen, but not the frames
+	_, err := io.ReadFull(client, make([]byte, 19))
+	assert.Nil(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The session kept waiting for a client that doesn't read")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Speeds are the multiples of the normal speed the speed hotkeys go through.
+var Speeds = []float64{0.25, 0.5, 1, 2, 4, SpeedUnlimited}
+
+// NextSpeed returns the speed after the current one, going faster when steps
+// is positive and slower when it is negative, up to the fastest and slowest.
+func NextSpeed(current float64, steps int) float64 {
+	index := len(Speeds) - 1
+
+	for i, speed := range Speeds {
+		if speed >= current {
+			index = i
+			break
+		}
+	}
+
+	index += steps
+
+	if index < 0 {
+		index = 0
+	}
+
+	if index >= len(Speeds) {
+		index = len(Speeds) - 1
+	}
+
+	return Speeds[index]
+}
+
+// FormatSpeed shows a speed like 2x or 0.25x.
+func FormatSpeed(speed float64) string {
+	if math.IsInf(speed, 1) {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%gx", speed)
+}
+
+// ParseSpeed reads a speed like 2, 0.5x or unlimited. Anything after the
+// number but the x, and speeds that aren't a positive finite number, are
+// refused.
+func ParseSpeed(name string) (float64, error) {
+	trimmed := strings.TrimSpace(name)
+
+	if trimmed == "unlimited" {
+		return SpeedUnlimited, nil
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(trimmed, "x"), 64)
+
+	if err != nil || math.IsNaN(speed) || math.IsInf(speed, 0) || speed <= 0 {
+		return 0, fmt.Errorf("Invalid speed: %s", name)
+	}
+
+	return speed, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextSpeed(t *testing.T) {
+	assert.Equal(t, NextSpeed(1, 1), 2.0)
+	assert.Equal(t, NextSpeed(1, -1), 0.5)
+	assert.Equal(t, NextSpeed(4, 1), SpeedUnlimited)
+	assert.Equal(t, NextSpeed(SpeedUnlimited, 1), SpeedUnlimited)
+	assert.Equal(t, NextSpeed(0.25, -1), 0.25)
+
+	// Speeds that aren't in the list go to the next one
+	assert.Equal(t, NextSpeed(3, 0), 4.0)
+	assert.Equal(t, NextSpeed(3, -1), 2.0)
+}
+
+func TestParseSpeed(t *testing.T) {
+	speeds := map[string]float64{
+		"1":         1,
+		"2x":        2,
+		"0.25":      0.25,
+		"unlimited": SpeedUnlimited,
+	}
+
+	for name, expected := range speeds {
+		speed, err := ParseSpeed(name)
+
+		assert.Nil(t, err)
+		assert.Equal(t, speed, expected)
+		assert.Equal(t, FormatSpeed(speed), map[string]string{"1": "1x", "2x": "2x", "0.25": "0.25x", "unlimited": "unlimited"}[name])
+	}
+
+	for _, name := range []string{"fast", "0", "-2", "", "nan", "NaN", "inf", "2abc", "2xx"} {
+		_, err := ParseSpeed(name)
+		assert.EqualError(t, err, "Invalid speed: "+name)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "fmt"
+
+// State is a snapshot of everything that changes while a ROM runs, used to save
+// and restore the VM.
+type State struct {
+	Memory [memorySize]byte
+	V      [16]uint8
+	Stack  [16]uint16
+
+	PC uint16
+	SP uint8
+	I  uint16
+
+	DT uint8
+	ST uint8
+
+	Pixels [width * height]byte
+	Keys   [16]bool
+}
+
+// Validate returns an error when PC or I point past the end of memory, or SP
+// past the end of the stack.
+func (state State) Validate() error {
+	if int(state.PC)+1 >= len(state.Memory) {
+		return fmt.Errorf("PC is out of memory: %X", state.PC)
+	}
+
+	if int(state.SP) >= len(state.Stack) {
+		return fmt.Errorf("SP is out of the stack: %d", state.SP)
+	}
+
+	if int(state.I) >= len(state.Memory) {
+		return fmt.Errorf("I is out of memory: %X", state.I)
+	}
+
+	return nil
+}
+
+// SaveState returns a snapshot of the VM.
+func (vm *VM) SaveState() State {
+	return State{
+		Memory: vm.Memory,
+		V:      vm.V,
+		Stack:  vm.Stack,
+		PC:     vm.PC,
+		SP:     vm.SP,
+		I:      vm.I,
+		DT:     vm.DT,
+		ST:     vm.ST,
+		Pixels: vm.Screen.Pixels,
+		Keys:   vm.Keypad.keys,
+	}
+}
+
+// RestoreState puts the VM back in the state of the snapshot.
+func (vm *VM) RestoreState(state State) {
+	vm.Memory = state.Memory
+	vm.V = state.V
+	vm.Stack = state.Stack
+	vm.PC = state.PC
+	vm.SP = state.SP
+	vm.I = state.I
+	vm.DT = state.DT
+	vm.ST = state.ST
+	vm.Keypad.keys = state.Keys
+
+	vm.Screen.Pixels = state.Pixels
+	for row := range vm.Screen.dirty {
+		vm.Screen.dirty[row] = true
+	}
+}
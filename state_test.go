@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndRestoreState(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.LoadProgram([]byte{0x60, 0x05, 0xD0, 0x05})
+	vm.Step()
+	vm.Step()
+	vm.Keypad.PressKey(0xA)
+
+	state := vm.SaveState()
+	vm.Step()
+	vm.Screen.Clear()
+	vm.Keypad.ReleaseKey(0xA)
+	vm.Screen.Frame()
+
+	vm.RestoreState(state)
+
+	assert.Equal(t, vm.PC, uint16(0x204))
+	assert.Equal(t, vm.V[0], uint8(0x05))
+	assert.Equal(t, vm.Screen.Pixels, state.Pixels)
+	assert.Equal(t, vm.Keypad.CheckPressed(0xA), true)
+	assert.Equal(t, vm.Screen.Dirty(), true)
+}
+
+func TestValidateState(t *testing.T) {
+	assert.Nil(t, State{PC: 0xFFE, SP: 15, I: 0xFFF}.Validate())
+	assert.EqualError(t, State{PC: 0xFFF}.Validate(), "PC is out of memory: FFF")
+	assert.EqualError(t, State{SP: 16}.Validate(), "SP is out of the stack: 16")
+	assert.EqualError(t, State{I: 0x1000}.Validate(), "I is out of memory: 1000")
+}
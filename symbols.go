@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a label of an address in a ROM.
+type Symbol struct {
+	Address uint16
+	Name    string
+}
+
+// Symbols maps the addresses of a ROM to the labels and source lines it was
+// assembled from. Symbol files list one address per line, followed by a label
+// or by a source position written as file:line:
+//
+//	# Comments start with #
+//	0x200 main
+//	0x200 game.8o:12
+//	0x2A4 draw_paddle
+//
+// A nil Symbols knows no names, so addresses are shown as numbers.
+type Symbols struct {
+	labels []Symbol          // Sorted by address
+	lines  map[uint16]string // Source position of each instruction
+}
+
+var sourcePosition = regexp.MustCompile(`:\d+$`)
+
+// ParseSymbols reads a symbol file.
+func ParseSymbols(reader io.Reader) (*Symbols, error) {
+	symbols := &Symbols{lines: map[uint16]string{}}
+	scanner := bufio.NewScanner(reader)
+
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		address, err := strconv.ParseUint(fields[0], 0, 16)
+
+		if len(fields) != 2 || err != nil {
+			return nil, fmt.Errorf("Invalid symbol on line %d: %s", number, line)
+		}
+
+		if sourcePosition.MatchString(fields[1]) {
+			symbols.lines[uint16(address)] = fields[1]
+		} else {
+			symbols.labels = append(symbols.labels, Symbol{Address: uint16(address), Name: fields[1]})
+		}
+	}
+
+	sort.SliceStable(symbols.labels, func(i, j int) bool {
+		return symbols.labels[i].Address < symbols.labels[j].Address
+	})
+
+	return symbols, scanner.Err()
+}
+
+// SymbolsForROM loads the symbol file at path, or the ".sym" file next to the
+// ROM if there is one. It returns nil when there are no symbols.
+func SymbolsForROM(romPath, path string) (*Symbols, error) {
+	if path == "" {
+		path = strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sym"
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseSymbols(file)
+}
+
+// Label returns the name of the closest label at or before the address, and
+// the offset of the address from it.
+func (symbols *Symbols) Label(address uint16) (string, uint16, bool) {
+	if symbols == nil {
+		return "", 0, false
+	}
+
+	i := sort.Search(len(symbols.labels), func(i int) bool {
+		return symbols.labels[i].Address > address
+	})
+
+	if i == 0 {
+		return "", 0, false
+	}
+
+	label := symbols.labels[i-1]
+
+	return label.Name, address - label.Address, true
+}
+
+// Line returns the source position the instruction at the address was
+// assembled from.
+func (symbols *Symbols) Line(address uint16) (string, bool) {
+	if symbols == nil {
+		return "", false
+	}
+
+	line, ok := symbols.lines[address]
+
+	return line, ok
+}
+
+// Location describes an address as label+offset and file:line, with the
+// parts that are known.
+func (symbols *Symbols) Location(address uint16) string {
+	parts := []string{}
+
+	if name, offset, ok := symbols.Label(address); ok {
+		if offset > 0 {
+			name += fmt.Sprintf("+%d", offset)
+		}
+
+		parts = append(parts, name)
+	}
+
+	if line, ok := symbols.Line(address); ok {
+		parts = append(parts, line)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Describe returns the address as a number followed by its location.
+func (symbols *Symbols) Describe(address uint16) string {
+	description := fmt.Sprintf("0x%03X", address)
+
+	if location := symbols.Location(address); location != "" {
+		description += " " + location
+	}
+
+	return description
+}
+
+// Disassemble disassembles an instruction, naming the address it refers to
+// when it has a label.
+func (symbols *Symbols) Disassemble(op uint16) string {
+	instruction := Disassemble(op)
+
+	switch op & 0xF000 {
+	case 0x1000, 0x2000, 0xA000, 0xB000:
+		address := op & 0x0FFF
+
+		if name, offset, ok := symbols.Label(address); ok && offset == 0 {
+			return strings.Replace(instruction, fmt.Sprintf("0x%03X", address), name, 1)
+		}
+	}
+
+	return instruction
+}
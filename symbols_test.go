@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSymbols = `# Symbols of a test ROM
+0x2A4 draw_paddle
+0x200 main
+0x200 game.8o:12
+0x202 game.8o:13
+0x300 paddle
+`
+
+func TestParseSymbols(t *testing.T) {
+	symbols, err := ParseSymbols(strings.NewReader(testSymbols))
+	assert.Nil(t, err)
+
+	name, offset, ok := symbols.Label(0x2A8)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, name, "draw_paddle")
+	assert.Equal(t, offset, uint16(4))
+
+	_, _, ok = symbols.Label(0x1FE)
+	assert.Equal(t, ok, false)
+
+	assert.Equal(t, symbols.Describe(0x202), "0x202 main+2 game.8o:13")
+	assert.Equal(t, symbols.Describe(0x100), "0x100")
+
+	_, err = ParseSymbols(strings.NewReader("main 0x200\n"))
+	assert.NotNil(t, err)
+}
+
+func TestSymbolsDisassemble(t *testing.T) {
+	symbols, _ := ParseSymbols(strings.NewReader(testSymbols))
+
+	assert.Equal(t, symbols.Disassemble(0x22A4), "CALL draw_paddle")
+	assert.Equal(t, symbols.Disassemble(0xA300), "LD I, paddle")
+	assert.Equal(t, symbols.Disassemble(0xA302), "LD I, 0x302")
+	assert.Equal(t, symbols.Disassemble(0x6300), "LD V3, 0x00")
+
+	var none *Symbols
+	assert.Equal(t, none.Disassemble(0x22A4), "CALL 0x2A4")
+	assert.Equal(t, none.Describe(0x2A4), "0x2A4")
+}
+
+func TestSymbolsForROM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	rom := filepath.Join(dir, "game.ch8")
+
+	symbols, err := SymbolsForROM(rom, "")
+	assert.Nil(t, err)
+	assert.Nil(t, symbols)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "game.sym"), []byte(testSymbols), 0644))
+
+	symbols, err = SymbolsForROM(rom, "")
+	assert.Nil(t, err)
+	assert.Equal(t, symbols.Location(0x200), "main game.8o:12")
+
+	_, err = SymbolsForROM(rom, filepath.Join(dir, "missing.sym"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,242 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+func init() {
+	frontends["terminal"] = NewTerminal
+}
+
+// Terminal draws the screen with termbox and reads keys from the terminal.
+// Terminals don't report key releases, so the keypad is reset periodically.
+type Terminal struct {
+	Mode    RenderMode
+	Palette Palette
+	Output  termbox.OutputMode
+	Panels  bool // Leave room for the panels drawn by RenderState
+
+	mode  RenderMode           // Mode used for the last render, resolved when Mode is ModeAuto
+	frame [width * height]byte // Intensities drawn by the last render
+
+	mutex   sync.Mutex
+	polling bool // PollEvent is waiting for termbox
+	closed  bool
+}
+
+func NewTerminal(config Config) (Frontend, error) {
+	output, err := ParseColorMode(config.Colors)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Terminal{Mode: config.Mode, Palette: config.Palette, Output: output, Panels: config.Panels}, nil
+}
+
+func (terminal *Terminal) Init() error {
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+
+	terminal.Output = termbox.SetOutputMode(terminal.Output)
+
+	terminal.mutex.Lock()
+	terminal.closed = false
+	terminal.mutex.Unlock()
+
+	return nil
+}
+
+// Close closes termbox. A PollEvent still waiting is interrupted, so that it
+// doesn't read the keys meant for whatever uses termbox next.
+func (terminal *Terminal) Close() {
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+
+	if terminal.polling {
+		termbox.Interrupt()
+	}
+
+	terminal.closed = true
+	termbox.Close()
+}
+
+func (terminal *Terminal) Run(loop func() error) error {
+	return loop()
+}
+
+func (terminal *Terminal) ReportsReleases() bool {
+	return false
+}
+
+var hotkeyMap = map[termbox.Key]Hotkey{
+	termbox.KeyF2:   HotkeyScreenshot,
+	termbox.KeyF3:   HotkeyRecord,
+	termbox.KeyF5:   HotkeyPause,
+	termbox.KeyF6:   HotkeyStep,
+	termbox.KeyF7:   HotkeyReset,
+	termbox.KeyF8:   HotkeyHardReset,
+	termbox.KeyF4:   HotkeyFrame,
+	termbox.KeyPgup: HotkeyFaster,
+	termbox.KeyPgdn: HotkeySlower,
+}
+
+var buttonMap = map[termbox.Key]Button{
+	termbox.KeyArrowUp:    ButtonUp,
+	termbox.KeyArrowDown:  ButtonDown,
+	termbox.KeyArrowLeft:  ButtonLeft,
+	termbox.KeyArrowRight: ButtonRight,
+	termbox.KeySpace:      ButtonAction,
+}
+
+// PollEvent returns the next key press. Keys outside of the keypad that aren't
+// bound to a hotkey quit the emulator.
+func (terminal *Terminal) PollEvent() Event {
+	for {
+		event, ok := terminal.poll()
+		if !ok {
+			return Event{Quit: true}
+		}
+
+		if event.Type != termbox.EventKey {
+			continue
+		}
+
+		if hotkey, ok := hotkeyMap[event.Key]; ok {
+			return Event{Hotkey: hotkey}
+		}
+
+		if button, ok := buttonMap[event.Key]; ok {
+			return Event{Button: button}
+		}
+
+		key, ok := keyMap[event.Ch]
+
+		return Event{Key: key, Quit: !ok}
+	}
+}
+
+// poll waits for the next termbox event, until the terminal is closed.
+func (terminal *Terminal) poll() (termbox.Event, bool) {
+	terminal.mutex.Lock()
+	if terminal.closed {
+		terminal.mutex.Unlock()
+		return termbox.Event{}, false
+	}
+	terminal.polling = true
+	terminal.mutex.Unlock()
+
+	event := termbox.PollEvent()
+
+	terminal.mutex.Lock()
+	defer terminal.mutex.Unlock()
+	terminal.polling = false
+
+	return event, event.Type != termbox.EventInterrupt && !terminal.closed
+}
+
+// Render draws the rows of cells whose pixels changed since the last render
+// and flushes them to the terminal at once.
+func (terminal *Terminal) Render(frame *[width * height]byte) {
+	mode := terminal.resolveMode()
+	redraw := mode != terminal.mode
+
+	if redraw {
+		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+		terminal.mode = mode
+	}
+
+	columns, rows := mode.Size()
+	_, cellHeight := mode.CellSize()
+	gradient := terminal.Output != termbox.OutputNormal
+
+	for row := 0; row < rows; row++ {
+		if !redraw && !rowsChanged(&terminal.frame, frame, row*cellHeight, cellHeight) {
+			continue
+		}
+
+		for column := 0; column < columns; column++ {
+			cell := mode.Cell(frame, terminal.Palette, column, row, gradient)
+			termbox.SetCell(column, row, cell.Ch, terminal.attribute(cell.Fg), terminal.attribute(cell.Bg))
+		}
+	}
+
+	terminal.frame = *frame
+
+	termbox.Flush()
+}
+
+// resolveMode returns the render mode, picking one that fits the terminal in
+// auto mode.
+func (terminal *Terminal) resolveMode() RenderMode {
+	if terminal.Mode != ModeAuto {
+		return terminal.Mode
+	}
+
+	columns, rows := termbox.Size()
+
+	if terminal.Panels {
+		columns -= panelWidth + panelGap
+	}
+
+	return autoMode(columns, rows)
+}
+
+// RenderState draws the register and memory panels to the right of the
+// screen. The panels are redrawn every frame, termbox only sends the cells
+// that changed.
+func (terminal *Terminal) RenderState(state *State, debugger *Debugger) {
+	columns, _ := terminal.resolveMode().Size()
+	left := columns + panelGap
+
+	for y, line := range statePanel(state, debugger) {
+		text := []rune(line)
+
+		for x := 0; x < panelWidth; x++ {
+			ch := ' '
+			if x < len(text) {
+				ch = text[x]
+			}
+
+			termbox.SetCell(left+x, y, ch, termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+
+	termbox.Flush()
+}
+
+// attribute converts a color to the closest one the terminal can show.
+func (terminal *Terminal) attribute(color Color) termbox.Attribute {
+	switch terminal.Output {
+	case termbox.OutputRGB:
+		return termbox.RGBToAttribute(color.R, color.G, color.B)
+	case termbox.Output256:
+		return termbox.Attribute(color.Index256() + 1)
+	}
+
+	return termbox.ColorBlack + termbox.Attribute(color.Index16())
+}
+
+var colorModes = map[string]termbox.OutputMode{
+	"16":        termbox.OutputNormal,
+	"256":       termbox.Output256,
+	"truecolor": termbox.OutputRGB,
+}
+
+// ParseColorMode returns the termbox output mode for "16", "256" or "truecolor".
+func ParseColorMode(name string) (termbox.OutputMode, error) {
+	mode, ok := colorModes[name]
+
+	if !ok {
+		return termbox.OutputNormal, fmt.Errorf("Unknown color mode: %s", name)
+	}
+
+	return mode, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Color is a 24-bit RGB color.
+type Color struct {
+	R, G, B uint8
+}
+
+// ParseColor parses a color written as #RRGGBB.
+func ParseColor(hex string) (Color, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+
+	if err != nil || len(strings.TrimPrefix(hex, "#")) != 6 {
+		return Color{}, fmt.Errorf("Invalid color: %s", hex)
+	}
+
+	return Color{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value)}, nil
+}
+
+func (color Color) String() string {
+	return fmt.Sprintf("#%02X%02X%02X", color.R, color.G, color.B)
+}
+
+// Palette maps the value of a pixel to a color. Colors[0] is the background and
+// Colors[1] the foreground. Multi-plane output uses the plane bits of a pixel
+// as the index, so XO-CHIP needs four colors.
+type Palette struct {
+	Colors []Color
+}
+
+// Themes are the palettes that can be selected by name.
+var Themes = map[string]Palette{
+	"green":    {Colors: []Color{{0x00, 0x00, 0x00}, {0x33, 0xFF, 0x33}}},
+	"amber":    {Colors: []Color{{0x00, 0x00, 0x00}, {0xFF, 0xB0, 0x00}}},
+	"white":    {Colors: []Color{{0x00, 0x00, 0x00}, {0xFF, 0xFF, 0xFF}}},
+	"lcd":      {Colors: []Color{{0x9B, 0xBC, 0x0F}, {0x0F, 0x38, 0x0F}, {0x30, 0x62, 0x30}, {0x8B, 0xAC, 0x0F}}},
+	"inverted": {Colors: []Color{{0xFF, 0xFF, 0xFF}, {0x00, 0x00, 0x00}}},
+}
+
+const defaultTheme = "green"
+
+// ParsePalette returns the theme with the given name, or builds a palette from
+// a comma separated list of colors such as "#000000,#FFB000".
+func ParsePalette(spec string) (Palette, error) {
+	if theme, ok := Themes[spec]; ok {
+		return theme, nil
+	}
+
+	if !strings.HasPrefix(spec, "#") {
+		return Palette{}, fmt.Errorf("Unknown theme: %s", spec)
+	}
+
+	palette := Palette{}
+
+	for _, hex := range strings.Split(spec, ",") {
+		color, err := ParseColor(strings.TrimSpace(hex))
+
+		if err != nil {
+			return Palette{}, err
+		}
+
+		palette.Colors = append(palette.Colors, color)
+	}
+
+	if len(palette.Colors) < 2 {
+		return Palette{}, fmt.Errorf("A palette needs at least two colors: %s", spec)
+	}
+
+	return palette, nil
+}
+
+// PaletteForROM returns the palette set on the command line, or the one in a
+// ".palette" file next to the ROM, or the colors recommended for the ROM, or
+// the default theme.
+func PaletteForROM(romPath, spec, recommended string) (Palette, error) {
+	if spec == "" {
+		contents, err := ioutil.ReadFile(strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".palette")
+
+		if err != nil && !os.IsNotExist(err) {
+			return Palette{}, err
+		}
+
+		spec = strings.TrimSpace(string(contents))
+	}
+
+	if spec == "" {
+		spec = recommended
+	}
+
+	if spec == "" {
+		spec = defaultTheme
+	}
+
+	return ParsePalette(spec)
+}
+
+// Plane returns the color of a pixel with the given plane bits.
+func (palette Palette) Plane(bits byte) Color {
+	return palette.colors()[palette.planeIndex(bits)]
+}
+
+// planeIndex returns the index of the color used for the given plane bits.
+func (palette Palette) planeIndex(bits byte) int {
+	colors := palette.colors()
+
+	if int(bits) >= len(colors) {
+		return len(colors) - 1
+	}
+
+	return int(bits)
+}
+
+// Shade blends the background into the foreground by the given intensity.
+func (palette Palette) Shade(intensity byte) Color {
+	background := palette.Plane(0)
+	foreground := palette.Plane(1)
+
+	blend := func(from, to uint8) uint8 {
+		return uint8((int(from)*(0xFF-int(intensity)) + int(to)*int(intensity)) / 0xFF)
+	}
+
+	return Color{
+		R: blend(background.R, foreground.R),
+		G: blend(background.G, foreground.G),
+		B: blend(background.B, foreground.B),
+	}
+}
+
+func (palette Palette) colors() []Color {
+	if len(palette.Colors) < 2 {
+		return Themes[defaultTheme].Colors
+	}
+
+	return palette.Colors
+}
+
+// Levels of the 6x6x6 color cube of 256-color terminals.
+var cubeLevels = [6]int{0x00, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+
+// Colors 0-7 of a 16-color terminal, as xterm draws them.
+var ansiColors = [8]Color{
+	{0x00, 0x00, 0x00}, {0xCD, 0x00, 0x00}, {0x00, 0xCD, 0x00}, {0xCD, 0xCD, 0x00},
+	{0x00, 0x00, 0xEE}, {0xCD, 0x00, 0xCD}, {0x00, 0xCD, 0xCD}, {0xE5, 0xE5, 0xE5},
+}
+
+// Index256 returns the closest color of a 256-color terminal, picking from the
+// color cube (16-231) and the grayscale ramp (232-255).
+func (color Color) Index256() int {
+	nearest := func(value uint8) int {
+		best := 0
+
+		for i, level := range cubeLevels {
+			if abs(int(value)-level) < abs(int(value)-cubeLevels[best]) {
+				best = i
+			}
+		}
+
+		return best
+	}
+
+	r, g, b := nearest(color.R), nearest(color.G), nearest(color.B)
+	cube := Color{uint8(cubeLevels[r]), uint8(cubeLevels[g]), uint8(cubeLevels[b])}
+
+	average := (int(color.R) + int(color.G) + int(color.B)) / 3
+	gray := (average - 3) / 10
+	if gray < 0 {
+		gray = 0
+	} else if gray > 23 {
+		gray = 23
+	}
+	level := uint8(8 + gray*10)
+
+	if color.distance(Color{level, level, level}) < color.distance(cube) {
+		return 232 + gray
+	}
+
+	return 16 + r*36 + g*6 + b
+}
+
+// Index16 returns the closest of the eight basic terminal colors.
+func (color Color) Index16() int {
+	best := 0
+
+	for i, ansi := range ansiColors {
+		if color.distance(ansi) < color.distance(ansiColors[best]) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+func (color Color) distance(other Color) int {
+	r := int(color.R) - int(other.R)
+	g := int(color.G) - int(other.G)
+	b := int(color.B) - int(other.B)
+
+	return r*r + g*g + b*b
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColor(t *testing.T) {
+	color, err := ParseColor("#FFB000")
+	assert.Nil(t, err)
+	assert.Equal(t, color, Color{0xFF, 0xB0, 0x00})
+	assert.Equal(t, color.String(), "#FFB000")
+
+	_, err = ParseColor("#FFB")
+	assert.EqualError(t, err, "Invalid color: #FFB")
+
+	_, err = ParseColor("orange")
+	assert.EqualError(t, err, "Invalid color: orange")
+}
+
+func TestParsePalette(t *testing.T) {
+	palette, err := ParsePalette("amber")
+	assert.Nil(t, err)
+	assert.Equal(t, palette, Themes["amber"])
+
+	palette, err = ParsePalette("#000000, #FFFFFF")
+	assert.Nil(t, err)
+	assert.Equal(t, palette.Colors, []Color{{0, 0, 0}, {0xFF, 0xFF, 0xFF}})
+
+	_, err = ParsePalette("#000000")
+	assert.EqualError(t, err, "A palette needs at least two colors: #000000")
+
+	_, err = ParsePalette("sepia")
+	assert.EqualError(t, err, "Unknown theme: sepia")
+}
+
+func TestPaletteForROM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	rom := filepath.Join(dir, "game.ch8")
+
+	palette, err := PaletteForROM(rom, "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, palette, Themes["green"])
+
+	palette, err = PaletteForROM(rom, "", "amber")
+	assert.Nil(t, err)
+	assert.Equal(t, palette, Themes["amber"])
+
+	err = ioutil.WriteFile(filepath.Join(dir, "game.palette"), []byte("lcd\n"), 0644)
+	assert.Nil(t, err)
+
+	palette, err = PaletteForROM(rom, "", "amber")
+	assert.Nil(t, err)
+	assert.Equal(t, palette, Themes["lcd"])
+
+	palette, err = PaletteForROM(rom, "white", "amber")
+	assert.Nil(t, err)
+	assert.Equal(t, palette, Themes["white"])
+}
+
+func TestPalettePlane(t *testing.T) {
+	palette := Themes["lcd"]
+
+	assert.Equal(t, palette.Plane(0), Color{0x9B, 0xBC, 0x0F})
+	assert.Equal(t, palette.Plane(3), Color{0x8B, 0xAC, 0x0F})
+
+	palette = Themes["amber"]
+	assert.Equal(t, palette.Plane(3), Color{0xFF, 0xB0, 0x00})
+
+	assert.Equal(t, Palette{}.Plane(1), Themes["green"].Colors[1])
+}
+
+func TestPaletteShade(t *testing.T) {
+	palette := Themes["white"]
+
+	assert.Equal(t, palette.Shade(pixelOff), Color{0x00, 0x00, 0x00})
+	assert.Equal(t, palette.Shade(pixelOn), Color{0xFF, 0xFF, 0xFF})
+	assert.Equal(t, palette.Shade(0x80), Color{0x80, 0x80, 0x80})
+}
+
+func TestColorIndex256(t *testing.T) {
+	assert.Equal(t, Color{0x00, 0x00, 0x00}.Index256(), 16)
+	assert.Equal(t, Color{0xFF, 0xFF, 0xFF}.Index256(), 231)
+	assert.Equal(t, Color{0xFF, 0x00, 0x00}.Index256(), 196)
+	assert.Equal(t, Color{0x80, 0x80, 0x80}.Index256(), 244)
+}
+
+func TestColorIndex16(t *testing.T) {
+	assert.Equal(t, Color{0x33, 0xFF, 0x33}.Index16(), 2)
+	assert.Equal(t, Color{0xFF, 0xB0, 0x00}.Index16(), 3)
+	assert.Equal(t, Color{0x00, 0x00, 0x00}.Index16(), 0)
+}
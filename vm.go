@@ -2,16 +2,33 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
-	"math/rand"
+	"math"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	clockSpeed = time.Duration(120)
-	resetKeySpeed = time.Duration(6)
+	clockSpeed      = 120 // Instructions per second at normal speed
+	clockResolution = time.Duration(480)
+	frameSpeed      = time.Duration(60)
+	resetKeySpeed   = time.Duration(6)
+	unlimitedBatch  = 1000 // Instructions run between checks for events at unlimited speed
+	memorySize      = 4096 // Bytes of memory, 0x000 to 0xFFF
 )
 
+// SpeedUnlimited runs the VM as fast as the host allows.
+var SpeedUnlimited = math.Inf(1)
+
+// alwaysReady is the clock at unlimited speed, a closed channel.
+var alwaysReady = func() <-chan time.Time {
+	clock := make(chan time.Time)
+	close(clock)
+
+	return clock
+}()
+
 type UnknownOpCode struct {
 	OpCode uint16
 }
@@ -20,6 +37,34 @@ func (uo *UnknownOpCode) Error() string {
 	return "Unknown OpCode: " + fmt.Sprintf("%X", uo.OpCode)
 }
 
+// StackOverflow is returned by a CALL when the stack is full.
+type StackOverflow struct {
+	Address uint16
+}
+
+func (so *StackOverflow) Error() string {
+	return fmt.Sprintf("Stack overflow: CALL at %03X", so.Address)
+}
+
+// StackUnderflow is returned by a RET without a CALL to return from.
+type StackUnderflow struct {
+	Address uint16
+}
+
+func (su *StackUnderflow) Error() string {
+	return fmt.Sprintf("Stack underflow: RET at %03X", su.Address)
+}
+
+// OutOfMemory is returned when the program counter or an instruction goes
+// past the end of memory.
+type OutOfMemory struct {
+	Address int
+}
+
+func (oom *OutOfMemory) Error() string {
+	return fmt.Sprintf("Out of memory: address %X", oom.Address)
+}
+
 type VM struct {
 	// The 4096 bytes of memory.
 	//
@@ -47,7 +92,7 @@ type VM struct {
 	// |  interpreter  |
 	// +---------------+= 0x000 (0) Start of Chip-8 RAM
 
-	Memory [4095]byte
+	Memory [memorySize]byte
 	V      [16]uint8 // 16 Registers (V0 to VF)
 	Stack  [16]uint16
 
@@ -55,27 +100,67 @@ type VM struct {
 	SP uint8  // Stack pointer
 	I  uint16 // Index register
 
-	Screen *Screen
-	Keypad Keypad
-	Logger log.Logger
-
-	Clock          <-chan time.Time // Timer
+	Screen   *Screen
+	Display  Display // Shows the screen, nil when running headless
+	Input    Input
+	Speaker  Speaker   // Plays the buzzer, nil when there is no sound output
+	Debugger *Debugger // Pauses on breakpoints, nil when not debugging
+	Keypad   Keypad
+	Buttons  map[Button]byte // Keypad keys pressed by the buttons outside of the keypad
+	Quirks   Quirks
+	Logger   log.Logger
+
+	Clock          <-chan time.Time // Paces the instructions, see Speed
+	FrameClock     <-chan time.Time // Render timer
 	ResetKeysClock <-chan time.Time // Reset pressed keys timer
-	Render         chan int         // Render
-	Event          chan byte        // Key press
+	Event          chan Event       // Key press or release
 
 	DT uint8 // Delay Timer
 	ST uint8 // Sound Timer
+
+	TickRate int     // Instructions per second at normal speed
+	Speed    float64 // Multiple of the normal speed, or SpeedUnlimited
+
+	hotkeys    map[Hotkey]func()
+	frameHooks []func()
+	stepHooks  []func(pc, op uint16)
+	program    []byte // Loaded by a hard reset
+	beeping    bool
+	clock      *time.Ticker
+	cycles     float64 // Instructions owed by the clock, see cyclesDue
+	tickers    []*time.Ticker
 }
 
-func InitVM() VM {
-	instance := VM{
-		PC:    0x200,
-		Clock: time.Tick(time.Second / clockSpeed),
-		ResetKeysClock: time.Tick(time.Second / resetKeySpeed),
-	}
-	instance.Render = make(chan int, 5)
-	instance.Event = make(chan byte, 10)
+// Quirks select the behavior of the instructions that CHIP-8 variants
+// disagree on. The zero value is how the VM has always run ROMs: shifts and
+// loads like SCHIP, jumps and logic like the COSMAC VIP.
+type Quirks struct {
+	ShiftVy       bool // 8xy6 and 8xyE shift Vy into Vx instead of shifting Vx
+	IncrementI    bool // Fx55 and Fx65 leave I after the last register
+	JumpVx        bool // Bxnn jumps to xnn plus Vx instead of V0
+	LogicResetsVF bool // 8xy1, 8xy2 and 8xy3 reset VF
+}
+
+// QuirksVIP runs ROMs like the COSMAC VIP, the original CHIP-8 interpreter.
+var QuirksVIP = Quirks{ShiftVy: true, IncrementI: true, LogicResetsVF: true}
+
+func InitVM() *VM {
+	instance := InitHeadlessVM()
+	instance.clock = instance.tick(time.Second / clockResolution)
+	instance.Clock = instance.clock.C
+	instance.FrameClock = instance.tick(time.Second / frameSpeed).C
+	instance.ResetKeysClock = instance.tick(time.Second / resetKeySpeed).C
+
+	return instance
+}
+
+// InitHeadlessVM returns a VM without clocks or display, which runs only when
+// Step is called.
+func InitHeadlessVM() *VM {
+	instance := &VM{PC: 0x200, Screen: &Screen{}, TickRate: clockSpeed, Speed: 1}
+	instance.Event = make(chan Event, 10)
+	instance.hotkeys = map[Hotkey]func(){}
+	instance.Logger.SetOutput(ioutil.Discard)
 
 	for i, v := range Fonts {
 		instance.Memory[i] = v
@@ -84,11 +169,85 @@ func InitVM() VM {
 	return instance
 }
 
+// tick returns a ticker that is stopped by Close.
+func (vm *VM) tick(interval time.Duration) *time.Ticker {
+	ticker := time.NewTicker(interval)
+	vm.tickers = append(vm.tickers, ticker)
+
+	return ticker
+}
+
+// SetTickRate sets how many instructions run per second at normal speed.
+func (vm *VM) SetTickRate(rate int) {
+	if rate > 0 {
+		vm.TickRate = rate
+	}
+}
+
+// SetSpeed runs the VM at a multiple of its tick rate, or as fast as possible
+// with SpeedUnlimited.
+func (vm *VM) SetSpeed(speed float64) {
+	vm.Speed = speed
+	vm.cycles = 0
+
+	if vm.clock == nil {
+		return
+	}
+
+	if math.IsInf(speed, 1) {
+		vm.Clock = alwaysReady
+	} else {
+		vm.Clock = vm.clock.C
+	}
+}
+
+// cyclesDue returns how many instructions to run on a tick of the clock.
+// Fractions add up over ticks, so that slow speeds run one every few ticks.
+func (vm *VM) cyclesDue() int {
+	if math.IsInf(vm.Speed, 1) {
+		return unlimitedBatch
+	}
+
+	vm.cycles += float64(vm.TickRate) * vm.Speed / float64(clockResolution)
+	due := int(vm.cycles)
+	vm.cycles -= float64(due)
+
+	return due
+}
+
+// Close stops the clocks of the VM once it is no longer used.
+func (vm *VM) Close() {
+	for _, ticker := range vm.tickers {
+		ticker.Stop()
+	}
+}
+
 func (vm *VM) SetScreen(screen *Screen) {
 	vm.Screen = screen
 }
 
+// OnHotkey registers the function run when the hotkey is pressed.
+func (vm *VM) OnHotkey(hotkey Hotkey, fn func()) {
+	vm.hotkeys[hotkey] = fn
+}
+
+// OnFrame registers a function run once per frame, after the screen is rendered.
+func (vm *VM) OnFrame(fn func()) {
+	vm.frameHooks = append(vm.frameHooks, fn)
+}
+
+// OnStep registers a function run after every instruction, with the address
+// and opcode of the instruction.
+func (vm *VM) OnStep(fn func(pc, op uint16)) {
+	vm.stepHooks = append(vm.stepHooks, fn)
+}
+
 func (vm *VM) Step() error {
+	if err := vm.checkPC(); err != nil {
+		return err
+	}
+
+	pc := vm.PC
 	op := vm.decodeOpCode()
 
 	err := vm.ExecOp(op)
@@ -96,6 +255,10 @@ func (vm *VM) Step() error {
 		return err
 	}
 
+	for _, fn := range vm.stepHooks {
+		fn(pc, op)
+	}
+
 	if vm.DT > 0 {
 		vm.DT -= 1
 	}
@@ -110,331 +273,501 @@ func (vm *VM) Start() error {
 	for {
 		select {
 		case event := <-vm.Event:
-			if event == 0x00 {
+			switch {
+			case event.Quit:
 				return nil
+			case event.Hotkey != NoHotkey:
+				if fn, ok := vm.hotkeys[event.Hotkey]; ok {
+					fn()
+				}
+			case event.Button != NoButton:
+				if key, ok := vm.Buttons[event.Button]; ok && event.Released {
+					vm.Keypad.ReleaseKey(key)
+				} else if ok {
+					vm.Keypad.PressKey(key)
+				}
+			case event.Released:
+				vm.Keypad.ReleaseKey(event.Key)
+			default:
+				vm.Keypad.PressKey(event.Key)
 			}
-			vm.Keypad.PressKey(event)
-		case <- vm.ResetKeysClock:
+		case <-vm.ResetKeysClock:
 			vm.Keypad.Reset()
 		case <-vm.Clock:
-			if err := vm.Step(); err != nil {
-				return err
+			for due := vm.cyclesDue(); due > 0 && !vm.Debugger.IsPaused(); due-- {
+				if err := vm.DebugStep(); err != nil {
+					return err
+				}
+			}
+		case <-vm.FrameClock:
+			// Draws are coalesced into a single render per frame
+			if vm.Display != nil && (vm.Screen.Dirty() || !vm.Screen.Settled()) {
+				vm.Display.Render(vm.Screen.Frame())
 			}
-		case <-vm.Render:
-			vm.Screen.Render()
+
+			vm.updateSpeaker()
+
+			for _, fn := range vm.frameHooks {
+				fn()
+			}
+		}
+	}
+}
+
+// DebugStep runs one instruction, tracing it, and pauses the VM if a
+// breakpoint of the debugger holds after it.
+func (vm *VM) DebugStep() error {
+	_, err := vm.debugStep()
+
+	return err
+}
+
+// debugStep runs one instruction like DebugStep and tells whether a
+// breakpoint held.
+func (vm *VM) debugStep() (bool, error) {
+	if err := vm.checkPC(); err != nil {
+		return false, err
+	}
+
+	op := vm.decodeOpCode()
+	vm.Debugger.trace(vm, op)
+
+	if err := vm.Step(); err != nil {
+		return false, err
+	}
+
+	if vm.Debugger.Check(vm, op) {
+		vm.Logger.Printf("%s at %s", vm.Debugger.Status(), vm.Debugger.Symbols.Describe(vm.PC))
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// AdvanceFrame runs the instructions of one frame at normal speed, stopping
+// early when a breakpoint holds. It goes through a paused ROM a frame at a
+// time, which shows the screen as the ROM draws it.
+func (vm *VM) AdvanceFrame() error {
+	for i := 0; i == 0 || i < vm.TickRate/int(frameSpeed); i++ {
+		if hit, err := vm.debugStep(); hit || err != nil {
+			return err
 		}
 	}
+
+	return nil
+}
+
+// updateSpeaker sounds the buzzer while the sound timer is non-zero.
+func (vm *VM) updateSpeaker() {
+	beeping := vm.ST > 0
+
+	if vm.Speaker != nil && beeping != vm.beeping {
+		vm.Speaker.Beep(beeping)
+	}
+
+	vm.beeping = beeping
 }
 
+// EventListener forwards the events of the input to the VM until the user quits.
 func (vm *VM) EventListener() {
 	for {
-		vm.Event <- pollEvent()
+		event := vm.Input.PollEvent()
+		vm.Event <- event
+
+		if event.Quit {
+			return
+		}
 	}
 }
 
-func (vm *VM) ExecOp(op uint16) error {
-	switch op & 0xF000 {
-	case 0x0000: // SYS addr
-		switch op {
-		case 0x00E0: // CLS
-			vm.Screen.Clear()
+// opHandlers runs the instructions of each opcode class, indexed by the high
+// nibble of the opcode. A table avoids going through a chain of comparisons
+// for every instruction.
+var opHandlers = [16]func(vm *VM, op uint16) error{
+	(*VM).opSys,
+	(*VM).opJump,
+	(*VM).opCall,
+	(*VM).opSkipEqual,
+	(*VM).opSkipNotEqual,
+	(*VM).opSkipRegistersEqual,
+	(*VM).opLoad,
+	(*VM).opAdd,
+	(*VM).opArithmetic,
+	(*VM).opSkipRegistersNotEqual,
+	(*VM).opLoadIndex,
+	(*VM).opJumpOffset,
+	(*VM).opRandom,
+	(*VM).opDraw,
+	(*VM).opSkipKey,
+	(*VM).opMisc,
+}
 
-			vm.PC += 2
-			break
-		case 0x00EE: // RET
-			vm.PC = vm.Stack[vm.SP]
-			vm.SP--
-			vm.PC += 2
-			break
-		default:
-			return &UnknownOpCode{OpCode: op}
+// arithmeticOps runs the 8xyN instructions, indexed by N. Unused values of N
+// are nil.
+var arithmeticOps = [16]func(vm *VM, x, y uint16){
+	0x0: func(vm *VM, x, y uint16) { // 8xy0 - LD Vx, Vy
+		vm.V[x] = vm.V[y]
+	},
+	0x1: func(vm *VM, x, y uint16) { // 8xy1 - OR Vx, Vy
+		vm.V[x] = vm.V[x] | vm.V[y]
+		vm.resetVF()
+	},
+	0x2: func(vm *VM, x, y uint16) { // 8xy2 - AND Vx, Vy
+		vm.V[x] = vm.V[x] & vm.V[y]
+		vm.resetVF()
+	},
+	0x3: func(vm *VM, x, y uint16) { // 8xy3 - XOR Vx, Vy
+		vm.V[x] = vm.V[x] ^ vm.V[y]
+		vm.resetVF()
+	},
+	0x4: func(vm *VM, x, y uint16) { // 8xy4 - ADD Vx, Vy
+		sum := uint16(vm.V[x]) + uint16(vm.V[y])
+
+		var carryFlag byte
+		if sum > 255 {
+			carryFlag = 1
 		}
 
-		break
-	case 0x1000: // 1nnn - Jump address
-		vm.PC = op & 0x0FFF
-		break
-	case 0x2000: // 2nnn - Call addr
-		vm.SP++
-		vm.Stack[vm.SP] = vm.PC
-		vm.PC = op & 0x0FFF
-		break
-	case 0x3000: // 3xkk - SE Vx - Skip next instruction if Vx = kk.
-		x := op & 0x0F00 >> 8
-		kk := uint8(op & 0x00FF)
+		vm.V[0xF] = carryFlag
+		vm.V[x] = uint8(sum)
+	},
+	0x5: func(vm *VM, x, y uint16) { // 8xy5 - SUB Vx, Vy
+		sum := uint16(vm.V[x]) - uint16(vm.V[y])
 
-		vm.PC += 2
+		var carryFlag byte
+		if vm.V[x] > vm.V[y] {
+			carryFlag = 1
+		}
 
-		if vm.V[x] == kk {
-			vm.PC += 2
+		vm.V[0xF] = carryFlag
+		vm.V[x] = uint8(sum)
+	},
+	0x6: func(vm *VM, x, y uint16) { // 8xy6 - SHR Vx {, Vy}
+		var carryFlag byte
+		value := vm.shifted(x, y)
+
+		if (value & 0x01) == 0x01 {
+			carryFlag = 1
 		}
 
-		break
-	case 0x4000: // 4xkk - SNE Vx, byte - Skip next instruction if Vx != kk.
-		x := op & 0x0F00 >> 8
-		kk := uint8(op & 0x00FF)
+		vm.V[0xF] = carryFlag
+		vm.V[x] = value / 2
+	},
+	0x7: func(vm *VM, x, y uint16) { // 8xy7 SUBN Vx, Vy
+		sum := uint16(vm.V[y]) - uint16(vm.V[x])
 
-		vm.PC += 2
+		var carryFlag byte
+		if vm.V[y] > vm.V[x] {
+			carryFlag = 1
+		}
 
-		if vm.V[x] != kk {
-			vm.PC += 2
+		vm.V[0xF] = carryFlag
+		vm.V[x] = uint8(sum)
+	},
+	0xE: func(vm *VM, x, y uint16) { // 8xyE - SHL Vx {, Vy}
+		var carryFlag byte
+		value := vm.shifted(x, y)
+
+		if (value & 0x80) == 0x80 {
+			carryFlag = 1
 		}
 
-		break
-	case 0x5000: // 5xy0 - SE Vx, Vy
-		switch op & 0xF00F {
-		case 0x5000:
-			x := op & 0x0F00 >> 8
-			y := op & 0x00F0 >> 4
+		vm.V[0xF] = carryFlag
+		vm.V[x] = value * 2
+	},
+}
 
-			vm.PC += 2
+func (vm *VM) ExecOp(op uint16) error {
+	return opHandlers[op>>12](vm, op)
+}
 
-			if vm.V[x] == vm.V[y] {
-				vm.PC += 2
-			}
-			break
-		default:
-			return &UnknownOpCode{OpCode: op}
+func (vm *VM) opSys(op uint16) error {
+	switch op {
+	case 0x00E0: // CLS
+		vm.Screen.Clear()
+		vm.PC += 2
+	case 0x00EE: // RET
+		if vm.SP == 0 {
+			return &StackUnderflow{Address: vm.PC}
 		}
-		break
-	case 0x6000: // 6xkk - LD Vx, byte
-		x := op & 0x0F00 >> 8
-		kk := uint8(op & 0x00FF)
 
-		vm.V[x] = kk
+		vm.PC = vm.Stack[vm.SP]
+		vm.SP--
+		vm.PC += 2
+	default: // SYS addr
+		return &UnknownOpCode{OpCode: op}
+	}
+
+	return nil
+}
 
+// 1nnn - JP addr
+func (vm *VM) opJump(op uint16) error {
+	vm.PC = op & 0x0FFF
+	return nil
+}
+
+// 2nnn - CALL addr
+func (vm *VM) opCall(op uint16) error {
+	if int(vm.SP) >= len(vm.Stack)-1 {
+		return &StackOverflow{Address: vm.PC}
+	}
+
+	vm.SP++
+	vm.Stack[vm.SP] = vm.PC
+	vm.PC = op & 0x0FFF
+	return nil
+}
+
+// 3xkk - SE Vx, byte - Skip next instruction if Vx = kk.
+func (vm *VM) opSkipEqual(op uint16) error {
+	vm.PC += 2
+
+	if vm.V[op&0x0F00>>8] == uint8(op) {
 		vm.PC += 2
-		break
-	case 0x7000: // 7xkk - ADD Vx, byte
-		x := op & 0x0F00 >> 8
-		kk := uint8(op & 0x00FF)
-		vm.V[x] += kk
+	}
 
+	return nil
+}
+
+// 4xkk - SNE Vx, byte - Skip next instruction if Vx != kk.
+func (vm *VM) opSkipNotEqual(op uint16) error {
+	vm.PC += 2
+
+	if vm.V[op&0x0F00>>8] != uint8(op) {
 		vm.PC += 2
-		break
-	case 0x8000:
-		x := op & 0x0F00 >> 8
-		y := op & 0x00F0 >> 4
-
-		switch op & 0x000F {
-		case 0x0000: // 8xy0 - LD Vx, Vy
-			vm.V[x] = vm.V[y]
-			vm.PC += 2
-			break
-		case 0x0001: // 8xy1 - OR Vx, Vy
-			vm.V[x] = vm.V[x] | vm.V[y]
-			vm.PC += 2
-			break
-		case 0x0002: // 8xy2 - AND Vx, Vy
-			vm.V[x] = vm.V[x] & vm.V[y]
-			vm.PC += 2
-			break
-		case 0x0003: // 8xy3 - XOR Vx, Vy
-			vm.V[x] = vm.V[x] ^ vm.V[y]
-			vm.PC += 2
-			break
-		case 0x0004: // 8xy4 - ADD Vx, Vy
-			sum := uint16(vm.V[x]) + uint16(vm.V[y])
+	}
 
-			var carryFlag byte
-			if sum > 255 {
-				carryFlag = 1
-			}
+	return nil
+}
 
-			vm.V[0xF] = carryFlag
-			vm.V[x] = uint8(sum)
+// 5xy0 - SE Vx, Vy
+func (vm *VM) opSkipRegistersEqual(op uint16) error {
+	if op&0x000F != 0 {
+		return &UnknownOpCode{OpCode: op}
+	}
 
-			vm.PC += 2
+	vm.PC += 2
 
-			break
-		case 0x0005: // 8xy5 - SUB Vx, Vy
-			sum := uint16(vm.V[x]) - uint16(vm.V[y])
+	if vm.V[op&0x0F00>>8] == vm.V[op&0x00F0>>4] {
+		vm.PC += 2
+	}
 
-			var carryFlag byte
-			if vm.V[x] > vm.V[y] {
-				carryFlag = 1
-			}
+	return nil
+}
 
-			vm.V[0xF] = carryFlag
+// 6xkk - LD Vx, byte
+func (vm *VM) opLoad(op uint16) error {
+	vm.V[op&0x0F00>>8] = uint8(op)
+	vm.PC += 2
+	return nil
+}
 
-			vm.V[x] = uint8(sum)
+// 7xkk - ADD Vx, byte
+func (vm *VM) opAdd(op uint16) error {
+	vm.V[op&0x0F00>>8] += uint8(op)
+	vm.PC += 2
+	return nil
+}
 
-			vm.PC += 2
+// 8xyN - Arithmetic and logic between Vx and Vy
+func (vm *VM) opArithmetic(op uint16) error {
+	fn := arithmeticOps[op&0x000F]
 
-			break
+	if fn == nil {
+		return &UnknownOpCode{OpCode: op}
+	}
 
-		case 0x0006: // 8xy6 - SHR Vx {, Vy}
-			var carryFlag byte
+	fn(vm, op&0x0F00>>8, op&0x00F0>>4)
+	vm.PC += 2
+	return nil
+}
 
-			if (vm.V[x] & 0x01) == 0x01 {
-				carryFlag = 1
-			}
+// 9xy0 - SNE Vx, Vy
+func (vm *VM) opSkipRegistersNotEqual(op uint16) error {
+	if op&0x000F != 0 {
+		return &UnknownOpCode{OpCode: op}
+	}
 
-			vm.V[0xF] = carryFlag
+	vm.PC += 2
 
-			vm.V[x] /= 2
+	if vm.V[op&0x0F00>>8] != vm.V[op&0x00F0>>4] {
+		vm.PC += 2
+	}
 
-			vm.PC += 2
+	return nil
+}
 
-			break
-		case 0x0007: // 8xy7 SUBN Vx, Vy
-			sum := uint16(vm.V[y]) - uint16(vm.V[x])
+// Annn - LD I, addr
+func (vm *VM) opLoadIndex(op uint16) error {
+	vm.I = op & 0x0FFF
+	vm.PC += 2
+	return nil
+}
 
-			var carryFlag byte
+// Bnnn - JP V0, addr
+func (vm *VM) opJumpOffset(op uint16) error {
+	if vm.Quirks.JumpVx {
+		vm.PC = (op & 0x0FFF) + uint16(vm.V[op&0x0F00>>8])
+	} else {
+		vm.PC = (op & 0x0FFF) + uint16(vm.V[0])
+	}
 
-			if vm.V[y] > vm.V[x] {
-				carryFlag = 1
-			}
+	return nil
+}
 
-			vm.V[0xF] = carryFlag
+// Cxkk - RND Vx, byte
+func (vm *VM) opRandom(op uint16) error {
+	vm.V[op&0x0F00>>8] = byte(op) + randByte()
+	vm.PC += 2
+	return nil
+}
 
-			vm.V[x] = uint8(sum)
-			vm.PC += 2
+// Dxyn - DRW Vx, Vy, nibble
+func (vm *VM) opDraw(op uint16) error {
+	x := vm.V[op&0x0F00>>8]
+	y := vm.V[op&0x00F0>>4]
+	nibble := op & 0x000F
 
-			break
+	if err := vm.checkIndex(int(nibble)); err != nil {
+		return err
+	}
 
-		case 0x000E: // 8xyE - SHL Vx {, Vy}
-			var carryFlag byte
+	collision := vm.Screen.WriteSprite(vm.Memory[vm.I:vm.I+nibble], x, y)
 
-			if (vm.V[x] & 0x80) == 0x80 {
-				carryFlag = 1
-			}
+	if collision {
+		vm.V[0xF] = 1
+	} else {
+		vm.V[0xF] = 0
+	}
 
-			vm.V[0xF] = carryFlag
+	vm.PC += 2
+	return nil
+}
 
-			vm.V[x] *= 2
+func (vm *VM) opSkipKey(op uint16) error {
+	x := vm.V[op&0x0F00>>8]
 
+	switch op & 0x00FF {
+	case 0x009E: // Ex9E - SKP Vx
+		if vm.Keypad.CheckPressed(x) {
 			vm.PC += 2
-
-			break
-		default:
-			return &UnknownOpCode{OpCode: op}
 		}
-		break
-	case 0x9000: // 9xy0 SNE Vx, Vy
-		x := op & 0x0F00 >> 8
-		y := op & 0x00F0 >> 4
-
-		switch op & 0x000F {
-		case 0x0000:
+	case 0x00A1: // ExA1 - SKNP Vx
+		if !vm.Keypad.CheckPressed(x) {
 			vm.PC += 2
+		}
+	default:
+		return &UnknownOpCode{OpCode: op}
+	}
 
-			if vm.V[x] != vm.V[y] {
-				vm.PC += 2
-			}
+	vm.PC += 2
+	return nil
+}
 
-			break
-		default:
-			return &UnknownOpCode{OpCode: op}
+func (vm *VM) opMisc(op uint16) error {
+	x := op & 0x0F00 >> 8
+
+	switch op & 0x00FF {
+	case 0x0007: // LD Vx, DT
+		vm.V[x] = vm.DT
+	case 0x000A: // LD Vx, K
+		// Execution waits on this instruction until a key is pressed
+		key, pressed := vm.Keypad.Pressed()
+		if !pressed {
+			return nil
 		}
-	case 0xA000: // Annn - LD I, addr
-		vm.I = op & 0x0FFF
-		vm.PC += 2
-		break
-	case 0xB000: // Bnnn - JP v0, addr
-		vm.PC = (op & 0x0FFF) + uint16(vm.V[0])
-		break
-	case 0xC000: // Cxkk - RND Vx, byte
-		x := op & 0x0F00 >> 8
-		kk := byte(op)
 
-		vm.V[x] = kk + randByte()
-		vm.PC += 2
-		break
-	case 0xD000: // DRW Vx, Vy, nibble
-		x := vm.V[op&0x0F00>>8]
-		y := vm.V[op&0x00F0>>4]
-		nibble := op & 0x000F
-
-		collision := vm.Screen.WriteSprite(vm.Memory[vm.I:vm.I+nibble], x, y)
-
-		if collision {
-			vm.V[0xF] = 1
-		} else {
-			vm.V[0xF] = 0
+		vm.V[x] = key
+	case 0x0015: // LD DT, Vx
+		vm.DT = vm.V[x]
+	case 0x0018: // LD ST, Vx
+		vm.ST = vm.V[x]
+	case 0x001E: // ADD I, Vx
+		vm.I += uint16(vm.V[x])
+	case 0x0029: // LD F, Vx
+		vm.I = uint16(vm.V[x]) * 5
+	case 0x0033: // LD B, Vx
+		if err := vm.checkIndex(3); err != nil {
+			return err
 		}
 
-		vm.Render <- 0
+		vm.Memory[vm.I] = vm.V[x] / 100
+		vm.Memory[vm.I+1] = (vm.V[x] / 10) % 10
+		vm.Memory[vm.I+2] = (vm.V[x] % 100) % 10
+	case 0x0055: // LD [I], Vx
+		if err := vm.checkIndex(int(x) + 1); err != nil {
+			return err
+		}
 
-		vm.PC += 2
-		break
-	case 0xE000:
-		x := vm.V[op&0x0F00>>8]
-
-		switch op & 0x00FF {
-		case 0x009E: // Ex9E - SKP Vx
-			if vm.Keypad.CheckPressed(x) {
-				vm.PC += 2
-			}
+		for i := uint16(0); i <= x; i++ {
+			vm.Memory[vm.I+i] = vm.V[i]
+		}
 
-			vm.PC += 2
-			break
-		case 0x00A1: // ExA1 - SKPN Vx
-			if !vm.Keypad.CheckPressed(x) {
-				vm.PC += 2
-			}
+		if vm.Quirks.IncrementI {
+			vm.I += x + 1
+		}
+	case 0x0065: // LD Vx, [I]
+		if err := vm.checkIndex(int(x) + 1); err != nil {
+			return err
+		}
 
-			vm.PC += 2
-			break
-		default:
-			return &UnknownOpCode{OpCode: op}
+		for i := uint16(0); i <= x; i++ {
+			vm.V[i] = vm.Memory[vm.I+i]
 		}
 
-		break
-	case 0xF000:
-		x := op & 0x0F00 >> 8
+		if vm.Quirks.IncrementI {
+			vm.I += x + 1
+		}
+	default:
+		return &UnknownOpCode{OpCode: op}
+	}
 
-		switch op & 0x00FF {
-		case 0x0007: // LD Vx, DT
-			vm.V[x] = vm.DT
-			vm.PC += 2
-			break
-		case 0x000A: // LD Vx, K
-			vm.V[x] = fetchKey()
-			vm.PC += 2
-			break
-		case 0x0015: // LD DT, Vx
-			vm.DT = vm.V[x]
-			vm.PC += 2
-			break
-		case 0x0018: // LD ST, Vx
-			vm.ST = vm.V[x]
-			vm.PC += 2
-			break
-		case 0x001E: // ADD I, Vx
-			vm.I += uint16(vm.V[x])
-			vm.PC += 2
-			break
-		case 0x0029: // LD F, Vx
-			vm.I = uint16(vm.V[x]) * 5
-			vm.PC += 2
-			break
-		case 0x0033: // LD B, Vx
-			vm.Memory[vm.I] = vm.V[x] / 100
-			vm.Memory[vm.I+1] = (vm.V[x] / 10) % 10
-			vm.Memory[vm.I+2] = (vm.V[x] % 100) % 10
-			vm.PC += 2
-			break
-		case 0x0055: // LD [I], Vx
-			for i := 0; uint16(i) <= x; i++ {
-				vm.Memory[vm.I+uint16(i)] = vm.V[i]
-			}
+	vm.PC += 2
+	return nil
+}
 
-			vm.PC += 2
-			break
-		case 0x0065: // LD Vx, [I]
-			for i := 0; uint16(i) <= x; i++ {
-				vm.V[i] = vm.Memory[vm.I+uint16(i)]
-			}
+// shifted returns the register 8xy6 and 8xyE shift, Vx or Vy.
+func (vm *VM) shifted(x, y uint16) uint8 {
+	if vm.Quirks.ShiftVy {
+		return vm.V[y]
+	}
 
-			vm.PC += 2
-			break
-		default:
-			return &UnknownOpCode{OpCode: op}
-		}
+	return vm.V[x]
+}
+
+// resetVF clears VF after 8xy1, 8xy2 and 8xy3 when the quirk asks for it.
+func (vm *VM) resetVF() {
+	if vm.Quirks.LogicResetsVF {
+		vm.V[0xF] = 0
+	}
+}
+
+// indexBefore returns I as it was before op ran, for the instructions that
+// move it past the registers they load and store.
+func (vm *VM) indexBefore(op uint16) uint16 {
+	if vm.Quirks.IncrementI && (op&0xF0FF == 0xF055 || op&0xF0FF == 0xF065) {
+		return vm.I - (op&0x0F00>>8 + 1)
+	}
+
+	return vm.I
+}
+
+// checkPC returns an error when the instruction at PC doesn't fit in memory.
+func (vm *VM) checkPC() error {
+	if int(vm.PC)+1 >= len(vm.Memory) {
+		return &OutOfMemory{Address: int(vm.PC) + 1}
+	}
+
+	return nil
+}
 
-		break
+// checkIndex returns an error when the length bytes at I don't fit in memory.
+func (vm *VM) checkIndex(length int) error {
+	if int(vm.I)+length > len(vm.Memory) {
+		return &OutOfMemory{Address: int(vm.I) + length - 1}
 	}
+
 	return nil
 }
 
@@ -442,13 +775,77 @@ func (vm *VM) decodeOpCode() uint16 {
 	return uint16(vm.Memory[vm.PC])<<8 | uint16(vm.Memory[vm.PC+1])
 }
 
-func (vm *VM) LoadProgram(program []byte) {
-	for i, v := range program {
-		vm.Memory[i+512] = v
+// LoadProgram copies the program to memory at 0x200.
+func (vm *VM) LoadProgram(program []byte) error {
+	if err := vm.checkSize(program); err != nil {
+		return err
+	}
+
+	copy(vm.Memory[programStart:], program)
+	vm.program = program
+
+	return nil
+}
+
+func (vm *VM) checkSize(program []byte) error {
+	if len(program) > len(vm.Memory)-programStart {
+		return fmt.Errorf("Program is too large: %d bytes, at most %d fit in memory", len(program), len(vm.Memory)-programStart)
+	}
+
+	return nil
+}
+
+// Reset starts the program over. A soft reset clears the registers, timers,
+// keys and screen but keeps memory as the program left it, a hard reset also
+// reloads the fonts and the program.
+func (vm *VM) Reset(hard bool) {
+	state := State{PC: programStart, Memory: vm.Memory}
+
+	if hard {
+		state.Memory = [len(vm.Memory)]byte{}
+		copy(state.Memory[:], Fonts)
+		copy(state.Memory[programStart:], vm.program)
 	}
+
+	vm.RestoreState(state)
 }
 
-// randByte returns a random value between 0 and 255.
+// Reload replaces the program with a new version. The VM starts it over,
+// unless keepState is set, in which case it carries on with its registers,
+// timers and screen.
+func (vm *VM) Reload(program []byte, keepState bool) error {
+	if err := vm.checkSize(program); err != nil {
+		return err
+	}
+
+	state := vm.SaveState()
+	vm.program = program
+	vm.Reset(true)
+
+	if keepState {
+		state.Memory = vm.Memory
+		vm.RestoreState(state)
+	}
+
+	return nil
+}
+
+// randState is the state of the xorshift generator behind randByte, shared by
+// all VMs. It's never 0.
+var randState = uint64(time.Now().UnixNano()) | 1
+
+// randByte returns a random value between 0 and 255. Seeding a new source for
+// every call was slower than the rest of the instruction set put together.
 var randByte = func() byte {
-	return byte(rand.New(rand.NewSource(time.Now().UnixNano())).Intn(255))
+	for {
+		state := atomic.LoadUint64(&randState)
+
+		next := state ^ state<<13
+		next ^= next >> 7
+		next ^= next << 17
+
+		if atomic.CompareAndSwapUint64(&randState, state, next) {
+			return byte(next >> 56)
+		}
+	}
 }
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -11,17 +14,13 @@ func init() {
 	randByte = func() byte {
 		return 0x01
 	}
-
-	fetchKey = func() byte {
-		return 0x05
-	}
 }
 
 func TestInitVM(t *testing.T) {
 	vm := InitVM()
 
 	assert.Equal(t, vm.PC, uint16(0x200))
-	assert.Equal(t, len(vm.Memory), 4095)
+	assert.Equal(t, len(vm.Memory), 4096)
 }
 
 func TestLoadProgram(t *testing.T) {
@@ -30,9 +29,11 @@ func TestLoadProgram(t *testing.T) {
 
 	assert.Equal(t, vm.Memory[512:519], []byte{0, 0, 0, 0, 0, 0, 0})
 
-	vm.LoadProgram(program)
+	assert.Nil(t, vm.LoadProgram(program))
 
 	assert.Equal(t, vm.Memory[512:519], []byte("program"))
+	assert.Nil(t, vm.LoadProgram(make([]byte, 3584)))
+	assert.NotNil(t, vm.LoadProgram(make([]byte, 3585)))
 }
 
 func TestDecodeOpCode(t *testing.T) {
@@ -119,6 +120,11 @@ func TestExecOpRET(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Equal(t, vm.PC, uint16(0x302))
+
+	vm.SP = 0
+	err = vm.ExecOp(0x00EE)
+	assert.Equal(t, err, &StackUnderflow{Address: 0x302})
+	assert.Equal(t, vm.SP, uint8(0))
 }
 
 // Invalid SYS address
@@ -161,6 +167,35 @@ func TestExecOpCallAddr(t *testing.T) {
 	)
 }
 
+// CALL with a full stack
+func TestExecOpCallOverflow(t *testing.T) {
+	vm := InitVM()
+	vm.SP = 15
+
+	err := vm.ExecOp(0x2234)
+	assert.Equal(t, err, &StackOverflow{Address: 0x200})
+	assert.Equal(t, vm.SP, uint8(15))
+	assert.Equal(t, vm.PC, uint16(0x200))
+}
+
+func TestOutOfMemory(t *testing.T) {
+	vm := InitHeadlessVM()
+
+	vm.PC = 0xFFF
+	assert.Equal(t, vm.Step(), &OutOfMemory{Address: 0x1000})
+	assert.Equal(t, vm.DebugStep(), &OutOfMemory{Address: 0x1000})
+
+	vm.PC = 0x200
+	vm.I = 0xFFE
+
+	for _, op := range []uint16{0xD013, 0xF033, 0xF255, 0xF265} {
+		assert.Equal(t, vm.ExecOp(op), &OutOfMemory{Address: 0x1000})
+	}
+
+	assert.Nil(t, vm.ExecOp(0xD012))
+	assert.Equal(t, vm.PC, uint16(0x202))
+}
+
 // SE Vx
 func TestExecOpSEVx(t *testing.T) {
 	vm := InitVM()
@@ -695,9 +730,18 @@ func TestExecOpLDVxK(t *testing.T) {
 	assert.Equal(t, vm.PC, uint16(0x200))
 	assert.Equal(t, vm.V[2], uint8(0x0))
 
+	// Waits for a key press
 	err := vm.ExecOp(0xF20A)
 	assert.Nil(t, err)
 
+	assert.Equal(t, vm.PC, uint16(0x200))
+	assert.Equal(t, vm.V[2], uint8(0x0))
+
+	vm.Keypad.PressKey(0x05)
+
+	err = vm.ExecOp(0xF20A)
+	assert.Nil(t, err)
+
 	assert.Equal(t, vm.PC, uint16(0x202))
 	assert.Equal(t, vm.V[2], uint8(0x5))
 }
@@ -830,3 +874,226 @@ func TestExecOpLDVxI(t *testing.T) {
 	assert.Equal(t, vm.V[0:3], vm.Memory[0:3])
 	assert.Equal(t, vm.V[4:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
 }
+
+func TestQuirks(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.V[2] = 0x11
+	vm.V[4] = 0x81
+
+	// 8xy6 and 8xyE shift Vy into Vx
+	vm.Quirks = Quirks{ShiftVy: true}
+	assert.Nil(t, vm.ExecOp(0x8246))
+	assert.Equal(t, vm.V[2], uint8(0x40))
+	assert.Equal(t, vm.V[0xF], uint8(1))
+
+	assert.Nil(t, vm.ExecOp(0x824E))
+	assert.Equal(t, vm.V[2], uint8(0x02))
+	assert.Equal(t, vm.V[0xF], uint8(1))
+
+	// Fx55 and Fx65 move I past the registers
+	vm.Quirks = Quirks{IncrementI: true}
+	vm.I = 0x300
+	assert.Nil(t, vm.ExecOp(0xF255))
+	assert.Equal(t, vm.I, uint16(0x303))
+	assert.Equal(t, vm.indexBefore(0xF255), uint16(0x300))
+
+	assert.Nil(t, vm.ExecOp(0xF165))
+	assert.Equal(t, vm.I, uint16(0x305))
+
+	// Bxnn jumps with Vx
+	vm.Quirks = Quirks{JumpVx: true}
+	vm.V[4] = 0x10
+	assert.Nil(t, vm.ExecOp(0xB420))
+	assert.Equal(t, vm.PC, uint16(0x430))
+
+	// 8xy1 resets VF
+	vm.Quirks = Quirks{LogicResetsVF: true}
+	vm.V[0xF] = 1
+	assert.Nil(t, vm.ExecOp(0x8241))
+	assert.Equal(t, vm.V[0xF], uint8(0))
+}
+
+func TestStartButtons(t *testing.T) {
+	vm := InitHeadlessVM()
+	vm.Buttons = map[Button]byte{ButtonLeft: 0x4}
+
+	vm.Event <- Event{Button: ButtonLeft}
+	vm.Event <- Event{Button: ButtonUp}
+	vm.Event <- Event{Quit: true}
+	assert.Nil(t, vm.Start())
+
+	key, pressed := vm.Keypad.Pressed()
+	assert.Equal(t, pressed, true)
+	assert.Equal(t, key, uint8(0x4))
+
+	vm.Event <- Event{Button: ButtonLeft, Released: true}
+	vm.Event <- Event{Quit: true}
+	assert.Nil(t, vm.Start())
+
+	_, pressed = vm.Keypad.Pressed()
+	assert.Equal(t, pressed, false)
+}
+
+func TestReload(t *testing.T) {
+	vm := InitHeadlessVM()
+	assert.Nil(t, vm.LoadProgram([]byte{0x60, 0x01, 0x61, 0x02, 0x12, 0x04}))
+	assert.Nil(t, vm.Step())
+
+	assert.NotNil(t, vm.Reload(make([]byte, 3585), false))
+	assert.Equal(t, vm.PC, uint16(0x202))
+	assert.Equal(t, vm.V[0], uint8(1))
+
+	assert.Nil(t, vm.Reload([]byte{0x12, 0x00}, false))
+	assert.Equal(t, vm.PC, uint16(0x200))
+	assert.Equal(t, vm.V[0], uint8(0))
+	assert.Equal(t, vm.Memory[0x200:0x206], []byte{0x12, 0x00, 0, 0, 0, 0})
+	assert.Equal(t, vm.Memory[:5], Fonts[:5])
+}
+
+func TestReset(t *testing.T) {
+	// LD V0, 0xAB then LD I, 0x300 then LD [I], V0
+	vm := InitHeadlessVM()
+	assert.Nil(t, vm.LoadProgram([]byte{0x60, 0xAB, 0xA3, 0x00, 0xF0, 0x55}))
+	vm.Memory[0x10] = 0xFF
+	vm.Screen.Pixels[0] = 1
+	vm.Keypad.PressKey(0x3)
+	vm.DT = 10
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, vm.Step())
+	}
+
+	vm.Memory[0x200] = 0x61
+
+	vm.Reset(false)
+
+	assert.Equal(t, vm.PC, uint16(0x200))
+	assert.Equal(t, vm.V[0], uint8(0))
+	assert.Equal(t, vm.I, uint16(0))
+	assert.Equal(t, vm.DT, uint8(0))
+	assert.Equal(t, vm.Screen.Pixels[0], uint8(0))
+	assert.Equal(t, vm.Keypad.CheckPressed(0x3), false)
+	assert.Equal(t, vm.Memory[0x300], uint8(0xAB))
+	assert.Equal(t, vm.Memory[0x200], uint8(0x61))
+
+	vm.Reset(true)
+
+	assert.Equal(t, vm.PC, uint16(0x200))
+	assert.Equal(t, vm.Memory[0x300], uint8(0))
+	assert.Equal(t, vm.Memory[0x200], uint8(0x60))
+	assert.Equal(t, vm.Memory[0x10], Fonts[0x10])
+}
+
+func TestCyclesDue(t *testing.T) {
+	vm := InitHeadlessVM()
+
+	count := func(ticks int) int {
+		total := 0
+		for i := 0; i < ticks; i++ {
+			total += vm.cyclesDue()
+		}
+		return total
+	}
+
+	// One second of ticks
+	assert.Equal(t, count(480), 120)
+
+	vm.SetSpeed(2)
+	assert.Equal(t, count(480), 240)
+
+	vm.SetSpeed(0.25)
+	assert.Equal(t, count(480), 30)
+
+	vm.SetTickRate(600)
+	vm.SetSpeed(1)
+	assert.Equal(t, count(480), 600)
+
+	vm.SetSpeed(SpeedUnlimited)
+	assert.Equal(t, vm.cyclesDue(), unlimitedBatch)
+	assert.Nil(t, vm.Clock)
+}
+
+func TestSetSpeedClock(t *testing.T) {
+	vm := InitVM()
+	defer vm.Close()
+
+	vm.SetSpeed(SpeedUnlimited)
+	<-vm.Clock
+
+	vm.SetSpeed(1)
+	assert.NotEqual(t, vm.Clock, alwaysReady)
+}
+
+func TestAdvanceFrame(t *testing.T) {
+	// ADD V0, 1 in a loop
+	vm := InitHeadlessVM()
+	assert.Nil(t, vm.LoadProgram([]byte{0x70, 0x01, 0x12, 0x00}))
+	vm.Debugger = &Debugger{}
+	vm.Debugger.Pause()
+
+	assert.Nil(t, vm.AdvanceFrame())
+	assert.Equal(t, vm.V[0], uint8(1))
+	assert.Equal(t, vm.PC, uint16(0x200))
+
+	// A breakpoint stops the frame early
+	vm.SetTickRate(600)
+	assert.Nil(t, vm.Debugger.AddBreakpoint("V0 == 3"))
+	assert.Nil(t, vm.AdvanceFrame())
+	assert.Equal(t, vm.V[0], uint8(3))
+	assert.Equal(t, vm.Debugger.Status(), "Break: V0 == 3")
+}
+
+// benchmarkKeyHold is the number of instructions benchmarkROM holds a key down
+// for before moving on to the next one.
+const benchmarkKeyHold = 5000
+
+// benchmarkROM runs a ROM headlessly, one instruction per iteration, pressing
+// every key in turn so that games waiting for input carry on. ROMs that hit an
+// unknown opcode start over.
+func benchmarkROM(b *testing.B, path string, step func(vm *VM) error) {
+	rom, err := readROMFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	info, _ := LookupROM(rom)
+	vm := InitHeadlessVM()
+	vm.Configure(info)
+
+	if err := vm.LoadProgram(rom.Program); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+
+	for i := 0; i < b.N; i++ {
+		if i%benchmarkKeyHold == 0 {
+			vm.Keypad.Reset()
+			vm.Keypad.PressKey(uint8(i / benchmarkKeyHold % 16))
+		}
+
+		if err := step(vm); err != nil {
+			vm.Reset(true)
+		}
+	}
+
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "instructions/s")
+}
+
+func BenchmarkROMs(b *testing.B) {
+	paths, err := filepath.Glob("roms/*.ch8")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, path := range paths {
+		b.Run(strings.TrimSuffix(filepath.Base(path), ".ch8"), func(b *testing.B) {
+			benchmarkROM(b, path, (*VM).Step)
+		})
+	}
+}
+
+func BenchmarkDebugStep(b *testing.B) {
+	benchmarkROM(b, "roms/breakout.ch8", (*VM).DebugStep)
+}
@@ -0,0 +1,154 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Frames between two checks for changed files, 4 times per second.
+const watchFrames = 15
+
+// FileWatcher tells when a file changed by polling its modification time and
+// size.
+type FileWatcher struct {
+	Path string
+
+	modTime time.Time
+	size    int64
+}
+
+func NewFileWatcher(path string) *FileWatcher {
+	watcher := &FileWatcher{Path: path}
+	watcher.Changed()
+
+	return watcher
+}
+
+// Changed reports whether the file changed since the last call. A missing
+// file, like while an editor replaces it, doesn't count as a change.
+func (watcher *FileWatcher) Changed() bool {
+	info, err := os.Stat(watcher.Path)
+
+	if err != nil {
+		return false
+	}
+
+	changed := !info.ModTime().Equal(watcher.modTime) || info.Size() != watcher.size
+	watcher.modTime = info.ModTime()
+	watcher.size = info.Size()
+
+	return changed
+}
+
+// Reloader reloads a ROM into the VM when it changes on disk. With a source
+// and a build command, the ROM is rebuilt first whenever the source changes.
+type Reloader struct {
+	Path      string
+	Build     string // Shell command building the ROM from the source
+	KeepState bool   // Carry on with the registers, timers and screen instead of starting over
+
+	rom      *FileWatcher
+	source   *FileWatcher
+	frames   int
+	builds   chan buildResult // Result of the build running in the background
+	building bool
+	rebuild  bool // The source changed again during the build
+}
+
+// buildResult is the outcome of a build command.
+type buildResult struct {
+	output []byte
+	err    error
+}
+
+func NewReloader(path, source, build string, keepState bool) (*Reloader, error) {
+	if (source == "") != (build == "") {
+		return nil, errors.New("--watch-source and --build go together")
+	}
+
+	reloader := &Reloader{Path: path, Build: build, KeepState: keepState, rom: NewFileWatcher(path), builds: make(chan buildResult, 1)}
+
+	if source != "" {
+		reloader.source = NewFileWatcher(source)
+	}
+
+	return reloader, nil
+}
+
+// Frame checks for changes every few frames, meant to be registered with
+// OnFrame.
+func (reloader *Reloader) Frame(vm *VM) {
+	reloader.frames++
+
+	if reloader.frames%watchFrames == 0 {
+		reloader.Check(vm)
+	}
+}
+
+// Check rebuilds the ROM if the source changed, and reloads it if it changed.
+// Builds run in the background so that the VM doesn't freeze meanwhile, the
+// ROM is only looked at again once the build is done. Errors are logged, the
+// VM keeps running the last version that loaded.
+func (reloader *Reloader) Check(vm *VM) {
+	if reloader.source != nil && reloader.source.Changed() {
+		if reloader.building {
+			reloader.rebuild = true
+		} else {
+			reloader.startBuild()
+		}
+	}
+
+	if reloader.building {
+		select {
+		case result := <-reloader.builds:
+			reloader.building = false
+
+			if reloader.rebuild {
+				reloader.rebuild = false
+				reloader.startBuild()
+				return
+			}
+
+			if result.err != nil {
+				vm.Logger.Printf("Build failed: %s\n%s", result.err, result.output)
+				return
+			}
+		default:
+			return
+		}
+	}
+
+	if !reloader.rom.Changed() {
+		return
+	}
+
+	rom, err := readROMFile(reloader.Path)
+
+	if err == nil {
+		err = vm.Reload(rom.Program, reloader.KeepState)
+	}
+
+	if err != nil {
+		vm.Logger.Println(err)
+		return
+	}
+
+	vm.Logger.Println("Reloaded", reloader.Path)
+}
+
+// startBuild runs the build command in the background, its result is picked up
+// by a later Check.
+func (reloader *Reloader) startBuild() {
+	command := exec.Command("sh", "-c", reloader.Build)
+	reloader.building = true
+
+	go func() {
+		output, err := command.CombinedOutput()
+		reloader.builds <- buildResult{output: output, err: err}
+	}()
+}
@@ -0,0 +1,139 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// touch writes a file with a modification time in the future, so that the
+// change shows even on file systems with coarse timestamps.
+func touch(t *testing.T, path string, contents []byte, age int) {
+	assert.Nil(t, ioutil.WriteFile(path, contents, 0644))
+
+	modTime := time.Now().Add(time.Duration(age) * time.Second)
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "game.ch8")
+	touch(t, path, []byte{0x12, 0x00}, 0)
+
+	watcher := NewFileWatcher(path)
+	assert.Equal(t, watcher.Changed(), false)
+
+	touch(t, path, []byte{0x12, 0x02}, 1)
+	assert.Equal(t, watcher.Changed(), true)
+	assert.Equal(t, watcher.Changed(), false)
+
+	assert.Nil(t, os.Remove(path))
+	assert.Equal(t, watcher.Changed(), false)
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "game.ch8")
+	// LD V0, 0x01 then loop
+	touch(t, path, []byte{0x60, 0x01, 0x12, 0x02}, 0)
+
+	vm := InitHeadlessVM()
+	assert.Nil(t, vm.LoadProgram([]byte{0x60, 0x01, 0x12, 0x02}))
+	assert.Nil(t, vm.Step())
+	assert.Nil(t, vm.Step())
+
+	reloader, err := NewReloader(path, "", "", false)
+	assert.Nil(t, err)
+
+	reloader.Check(vm)
+	assert.Equal(t, vm.PC, uint16(0x202))
+
+	// LD V1, 0x02 then loop
+	touch(t, path, []byte{0x61, 0x02, 0x12, 0x02}, 1)
+	reloader.Check(vm)
+	assert.Equal(t, vm.PC, uint16(0x200))
+	assert.Equal(t, vm.V[0], uint8(0))
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x61, 0x02, 0x12, 0x02})
+
+	// Keeping the state
+	assert.Nil(t, vm.Step())
+	reloader.KeepState = true
+	touch(t, path, []byte{0x62, 0x03, 0x12, 0x02}, 2)
+	reloader.Check(vm)
+	assert.Equal(t, vm.PC, uint16(0x202))
+	assert.Equal(t, vm.V[1], uint8(2))
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x62, 0x03, 0x12, 0x02})
+
+	// A broken ROM is skipped
+	touch(t, path, []byte{}, 3)
+	reloader.Check(vm)
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x62, 0x03, 0x12, 0x02})
+}
+
+func TestReloaderBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "game.ch8")
+	source := filepath.Join(dir, "game.src")
+	touch(t, path, []byte{0x12, 0x00}, 0)
+	touch(t, source, []byte{0x12, 0x00}, 0)
+
+	_, err = NewReloader(path, source, "", false)
+	assert.EqualError(t, err, "--watch-source and --build go together")
+
+	reloader, err := NewReloader(path, source, "cp '"+source+"' '"+path+"'", false)
+	assert.Nil(t, err)
+
+	vm := InitHeadlessVM()
+	touch(t, source, []byte{0x60, 0x07, 0x12, 0x02}, 1)
+	waitForBuild(t, reloader, vm)
+
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x60, 0x07, 0x12, 0x02})
+
+	// The VM keeps running during the build
+	reloader.Build = "sleep 0.2 && " + reloader.Build
+	touch(t, source, []byte{0x61, 0x08, 0x12, 0x02}, 2)
+	reloader.Check(vm)
+
+	assert.Equal(t, reloader.building, true)
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x60, 0x07, 0x12, 0x02})
+
+	waitForBuild(t, reloader, vm)
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x61, 0x08, 0x12, 0x02})
+
+	// A failed build leaves the ROM alone
+	reloader.Build = "exit 1"
+	touch(t, source, []byte{0x62, 0x09, 0x12, 0x02}, 3)
+	waitForBuild(t, reloader, vm)
+
+	assert.Equal(t, vm.Memory[0x200:0x204], []byte{0x61, 0x08, 0x12, 0x02})
+}
+
+// waitForBuild checks for changes until the build started by the first check
+// is done.
+func waitForBuild(t *testing.T, reloader *Reloader, vm *VM) {
+	reloader.Check(vm)
+
+	for start := time.Now(); reloader.building; reloader.Check(vm) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("The build didn't finish")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}